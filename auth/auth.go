@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type (
+	// Config defines how tokens are issued and signed.
+	Config struct {
+		// SigningMethod is one of HS256, RS256 or ES256, default to HS256.
+		SigningMethod string
+
+		// Secret is the HMAC key used by HS256, for example cuxs.JwtKey().
+		Secret []byte
+
+		// PrivateKeyFile and PublicKeyFile are PEM files used by RS256 and ES256.
+		PrivateKeyFile string
+		PublicKeyFile  string
+
+		Issuer     string
+		Audience   string
+		AccessTTL  time.Duration
+		RefreshTTL time.Duration
+
+		// Store keeps the refresh token state, default to an in-memory store.
+		Store Store
+	}
+
+	// Token is the pair returned to the client after login or refresh.
+	Token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+
+	refreshClaims struct {
+		Type string `json:"typ"`
+		jwt.StandardClaims
+	}
+
+	// Issuer issues and rotates tokens.
+	Issuer struct {
+		config    Config
+		method    jwt.SigningMethod
+		signKey   interface{}
+		verifyKey interface{}
+	}
+)
+
+// RefreshTokenType is the typ claim of refresh tokens,
+// middleware.JWT refuses them as access tokens.
+const RefreshTokenType = "refresh"
+
+var (
+	ErrInvalidToken = errors.New("invalid refresh token")
+	ErrTokenReused  = errors.New("refresh token reused")
+)
+
+// New returns a token Issuer from config,
+// loading the signing keys from files when required.
+func New(config Config) (i *Issuer, err error) {
+	if config.SigningMethod == "" {
+		config.SigningMethod = jwt.SigningMethodHS256.Alg()
+	}
+
+	if config.AccessTTL == 0 {
+		config.AccessTTL = 15 * time.Minute
+	}
+
+	if config.RefreshTTL == 0 {
+		config.RefreshTTL = 30 * 24 * time.Hour
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+
+	i = &Issuer{config: config, method: jwt.GetSigningMethod(config.SigningMethod)}
+
+	switch config.SigningMethod {
+	case "HS256":
+		if len(config.Secret) == 0 {
+			return nil, errors.New("auth: HS256 requires a secret")
+		}
+
+		i.signKey, i.verifyKey = config.Secret, config.Secret
+	case "RS256":
+		var priv, pub []byte
+		if priv, pub, err = readKeys(config); err == nil {
+			if i.signKey, err = jwt.ParseRSAPrivateKeyFromPEM(priv); err == nil {
+				i.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pub)
+			}
+		}
+	case "ES256":
+		var priv, pub []byte
+		if priv, pub, err = readKeys(config); err == nil {
+			if i.signKey, err = jwt.ParseECPrivateKeyFromPEM(priv); err == nil {
+				i.verifyKey, err = jwt.ParseECPublicKeyFromPEM(pub)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("auth: unsupported signing method %s", config.SigningMethod)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("auth: cannot load keys, %s", err.Error())
+	}
+
+	return i, nil
+}
+
+// VerifyKey returns the key to verify issued tokens,
+// to be used as middleware.JWTConfig Key.
+func (i *Issuer) VerifyKey() interface{} {
+	return i.verifyKey
+}
+
+// SigningMethod returns the name of the signing method.
+func (i *Issuer) SigningMethod() string {
+	return i.config.SigningMethod
+}
+
+// Issue creates a new access and refresh token pair for subject,
+// extra claims are added to the access token.
+func (i *Issuer) Issue(subject string, claims map[string]interface{}) (*Token, error) {
+	family, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.issue(subject, family, claims)
+}
+
+// Refresh rotates the refresh token and issues a new token pair.
+// A refresh token that was already used revokes its whole family.
+func (i *Issuer) Refresh(refreshToken string, claims map[string]interface{}) (*Token, error) {
+	rc := refreshClaims{}
+	t, err := jwt.ParseWithClaims(refreshToken, &rc, i.keyFunc)
+	if err != nil || !t.Valid || rc.Type != RefreshTokenType {
+		return nil, ErrInvalidToken
+	}
+
+	s, err := i.config.Store.Get(rc.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil || s.Subject != rc.Subject {
+		return nil, ErrInvalidToken
+	}
+
+	if revoked, err := i.config.Store.IsRevoked(s.Family); err != nil {
+		return nil, err
+	} else if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	// only one of concurrent refreshes with the same token marks it used
+	used, err := i.config.Store.MarkUsed(rc.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if used {
+		i.config.Store.RevokeFamily(s.Family)
+		return nil, ErrTokenReused
+	}
+
+	return i.issue(rc.Subject, s.Family, claims)
+}
+
+// Revoke invalidates the refresh token and every token rotated from it.
+func (i *Issuer) Revoke(refreshToken string) error {
+	rc := refreshClaims{}
+	if _, err := jwt.ParseWithClaims(refreshToken, &rc, i.keyFunc); err != nil || rc.Type != RefreshTokenType {
+		return ErrInvalidToken
+	}
+
+	s, err := i.config.Store.Get(rc.Id)
+	if err != nil || s == nil {
+		return ErrInvalidToken
+	}
+
+	return i.config.Store.RevokeFamily(s.Family)
+}
+
+func (i *Issuer) issue(subject string, family string, claims map[string]interface{}) (*Token, error) {
+	now := time.Now()
+
+	ac := jwt.MapClaims{}
+	for k, v := range claims {
+		ac[k] = v
+	}
+
+	ac["sub"] = subject
+	ac["iat"] = now.Unix()
+	ac["nbf"] = now.Unix()
+	ac["exp"] = now.Add(i.config.AccessTTL).Unix()
+	if i.config.Issuer != "" {
+		ac["iss"] = i.config.Issuer
+	}
+
+	if i.config.Audience != "" {
+		ac["aud"] = i.config.Audience
+	}
+
+	access, err := jwt.NewWithClaims(i.method, ac).SignedString(i.signKey)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	rc := refreshClaims{
+		Type: RefreshTokenType,
+		StandardClaims: jwt.StandardClaims{
+			Id:        id,
+			Subject:   subject,
+			Issuer:    i.config.Issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(i.config.RefreshTTL).Unix(),
+		},
+	}
+
+	refresh, err := jwt.NewWithClaims(i.method, rc).SignedString(i.signKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{ID: id, Family: family, Subject: subject, ExpiresAt: now.Add(i.config.RefreshTTL)}
+	if err = i.config.Store.Save(s); err != nil {
+		return nil, err
+	}
+
+	return &Token{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(i.config.AccessTTL / time.Second),
+	}, nil
+}
+
+func (i *Issuer) keyFunc(t *jwt.Token) (interface{}, error) {
+	if t.Method.Alg() != i.config.SigningMethod {
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	}
+
+	return i.verifyKey, nil
+}
+
+func readKeys(config Config) (priv []byte, pub []byte, err error) {
+	if priv, err = ioutil.ReadFile(config.PrivateKeyFile); err == nil {
+		pub, err = ioutil.ReadFile(config.PublicKeyFile)
+	}
+
+	return
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+)
+
+func newTestIssuer(t *testing.T) *Issuer {
+	i, err := New(Config{Secret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return i
+}
+
+func TestRefreshRotates(t *testing.T) {
+	i := newTestIssuer(t)
+
+	tk, err := i.Issue("1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := i.Refresh(tk.RefreshToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = i.Refresh(tk.RefreshToken, nil); err != ErrTokenReused {
+		t.Fatalf("reused token: got %v, want %v", err, ErrTokenReused)
+	}
+
+	// the reuse revokes the whole family
+	if _, err = i.Refresh(next.RefreshToken, nil); err != ErrInvalidToken {
+		t.Fatalf("rotated token after reuse: got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRefreshConcurrent(t *testing.T) {
+	i := newTestIssuer(t)
+
+	tk, err := i.Issue("1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	for n := 0; n < 50; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := i.Refresh(tk.RefreshToken, nil); err == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Fatalf("%d concurrent refreshes succeeded, want 1", succeeded)
+	}
+}
+
+func TestRefreshRejectsAccessToken(t *testing.T) {
+	i := newTestIssuer(t)
+
+	tk, err := i.Issue("1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = i.Refresh(tk.AccessToken, nil); err != ErrInvalidToken {
+		t.Fatalf("access token refresh: got %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/qasico/cuxs/helper"
+)
+
+type (
+	// Session is the state kept for an issued refresh token.
+	Session struct {
+		ID        string    `json:"id"`
+		Family    string    `json:"family"`
+		Subject   string    `json:"subject"`
+		Used      bool      `json:"used"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	// Store keeps refresh token sessions and revoked families.
+	Store interface {
+		Save(s *Session) error
+		Get(id string) (*Session, error)
+		// MarkUsed marks the session used and reports whether it already
+		// was, atomically so concurrent refreshes cannot both succeed.
+		MarkUsed(id string) (used bool, err error)
+		RevokeFamily(family string) error
+		IsRevoked(family string) (bool, error)
+	}
+
+	// MemoryStore is a Store kept in process memory.
+	MemoryStore struct {
+		mutex    sync.Mutex
+		sessions map[string]Session
+		revoked  map[string]time.Time
+	}
+
+	// RedisStore is a Store kept in redis.
+	RedisStore struct {
+		Pool   *redis.Pool
+		Prefix string
+	}
+)
+
+// NewMemoryStore returns an empty in-memory Store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Session), revoked: make(map[string]time.Time)}
+}
+
+func (m *MemoryStore) Save(s *Session) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.gc()
+	m.sessions[s.ID] = *s
+
+	return nil
+}
+
+func (m *MemoryStore) Get(id string) (*Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if s, ok := m.sessions[id]; ok && s.ExpiresAt.After(time.Now()) {
+		return &s, nil
+	}
+
+	return nil, nil
+}
+
+func (m *MemoryStore) MarkUsed(id string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return false, ErrInvalidToken
+	}
+
+	used := s.Used
+	s.Used = true
+	m.sessions[id] = s
+
+	return used, nil
+}
+
+func (m *MemoryStore) RevokeFamily(family string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var exp time.Time
+	for id, s := range m.sessions {
+		if s.Family == family {
+			if s.ExpiresAt.After(exp) {
+				exp = s.ExpiresAt
+			}
+
+			delete(m.sessions, id)
+		}
+	}
+
+	m.revoked[family] = exp
+
+	return nil
+}
+
+func (m *MemoryStore) IsRevoked(family string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, ok := m.revoked[family]
+
+	return ok, nil
+}
+
+// gc removes expired sessions and revocations, must be called with the lock held.
+func (m *MemoryStore) gc() {
+	now := time.Now()
+	for id, s := range m.sessions {
+		if s.ExpiresAt.Before(now) {
+			delete(m.sessions, id)
+		}
+	}
+
+	for f, exp := range m.revoked {
+		if exp.Before(now) {
+			delete(m.revoked, f)
+		}
+	}
+}
+
+// NewRedisStore returns a Store connected with the given redis config,
+// usually taken from cuxs.Config.RedisConfig.
+func NewRedisStore(network string, address string, password string) *RedisStore {
+	return &RedisStore{
+		Prefix: "cuxs:auth:",
+		Pool:   helper.RedisPool(network, address, password),
+	}
+}
+
+func (r *RedisStore) Save(s *Session) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	c := r.Pool.Get()
+	defer c.Close()
+
+	ttl := int64(s.ExpiresAt.Sub(time.Now()) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	c.Send("MULTI")
+	c.Send("SET", r.Prefix+"session:"+s.ID, b, "EX", ttl)
+	c.Send("SADD", r.Prefix+"family:"+s.Family, s.ID)
+	c.Send("EXPIRE", r.Prefix+"family:"+s.Family, ttl)
+	_, err = c.Do("EXEC")
+
+	return err
+}
+
+func (r *RedisStore) Get(id string) (*Session, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+
+	b, err := redis.Bytes(c.Do("GET", r.Prefix+"session:"+id))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := new(Session)
+	if err = json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// markUsed sets used on the session json keeping its ttl, it returns -1
+// for a missing session, else 1 when the session was already used.
+var markUsed = redis.NewScript(1, `
+local v = redis.call("GET", KEYS[1])
+if not v then return -1 end
+local s = cjson.decode(v)
+if s.used then return 1 end
+s.used = true
+local ttl = redis.call("TTL", KEYS[1])
+if ttl < 1 then ttl = 1 end
+redis.call("SET", KEYS[1], cjson.encode(s), "EX", ttl)
+return 0`)
+
+func (r *RedisStore) MarkUsed(id string) (bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+
+	n, err := redis.Int(markUsed.Do(c, r.Prefix+"session:"+id))
+	if err != nil {
+		return false, err
+	} else if n < 0 {
+		return false, ErrInvalidToken
+	}
+
+	return n == 1, nil
+}
+
+func (r *RedisStore) RevokeFamily(family string) error {
+	c := r.Pool.Get()
+	defer c.Close()
+
+	ids, err := redis.Strings(c.Do("SMEMBERS", r.Prefix+"family:"+family))
+	if err != nil {
+		return err
+	}
+
+	// keep the revocation as long as the family could still be used
+	ttl, err := redis.Int64(c.Do("TTL", r.Prefix+"family:"+family))
+	if err != nil {
+		return err
+	}
+
+	c.Send("MULTI")
+	for _, id := range ids {
+		c.Send("DEL", r.Prefix+"session:"+id)
+	}
+	c.Send("DEL", r.Prefix+"family:"+family)
+	if ttl > 0 {
+		c.Send("SET", r.Prefix+"revoked:"+family, 1, "EX", ttl)
+	}
+	_, err = c.Do("EXEC")
+
+	return err
+}
+
+func (r *RedisStore) IsRevoked(family string) (bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+
+	return redis.Bool(c.Do("EXISTS", r.Prefix+"revoked:"+family))
+}
@@ -0,0 +1,305 @@
+package cuxs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
+	cerrors "github.com/qasico/cuxs/errors"
+	"github.com/qasico/cuxs/response"
+)
+
+type (
+	// BatchOptions configures the batch endpoint.
+	BatchOptions struct {
+		// MaxRequests limits the sub requests of a batch, default to 20.
+		MaxRequests int
+
+		// Parallel runs the sub requests concurrently by default,
+		// Concurrency limits how many run at once, default to 5.
+		Parallel    bool
+		Concurrency int
+
+		// AllowTransaction lets clients run a batch in a single database
+		// transaction, rolled back when any sub request fails.
+		AllowTransaction bool
+	}
+
+	// BatchRequest is a sub request of a batch.
+	BatchRequest struct {
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers"`
+		Body    json.RawMessage   `json:"body"`
+	}
+
+	// BatchResult is the response of a sub request.
+	BatchResult struct {
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body,omitempty"`
+	}
+
+	batchBody struct {
+		Requests    []BatchRequest `json:"requests"`
+		Parallel    *bool          `json:"parallel"`
+		Transaction bool           `json:"transaction"`
+	}
+
+	batchContextKey struct{}
+
+	// batchParent is what sub requests take from the batch request, read
+	// once as the engine request cannot be shared between goroutines.
+	batchParent struct {
+		host    string
+		headers map[string]string
+	}
+)
+
+var (
+	errBatchTransaction = cerrors.Conflict("batch_transaction", "Request does not support transactional batches")
+
+	// inherited headers are passed from the batch to its sub requests.
+	inheritedHeaders = []string{echo.HeaderAuthorization, "Accept-Language", "Cookie"}
+
+	// transactionalRoutes are the routes running on DBOf,
+	// keyed by their method and path as listed in Echo.Routes.
+	transactionalRoutes = map[string]bool{}
+	transactionalMutex  sync.RWMutex
+)
+
+// Batch registers on path an endpoint that takes an array of sub requests,
+// or an object with requests, parallel and transaction, and dispatches
+// them through the Echo router in process.
+//
+// A transactional batch runs its requests one by one in a single database
+// transaction, handed to the handlers by DBOf. Handlers writing through
+// ORM would commit outside of it, so only the routes of Resource and of
+// the routes marked with Transactional are accepted in such a batch.
+func Batch(path string, opts BatchOptions) {
+	if opts.MaxRequests == 0 {
+		opts.MaxRequests = 20
+	}
+
+	if opts.Concurrency == 0 {
+		opts.Concurrency = 5
+	}
+
+	Echo.POST(path, func(c echo.Context) error {
+		h, _ := NewHandler(c, nil)
+
+		b, err := ioutil.ReadAll(c.Request().Body())
+		if err != nil {
+			return h.Respond(err)
+		}
+
+		batch := batchBody{Parallel: &opts.Parallel}
+		if err = json.Unmarshal(b, &batch.Requests); err != nil {
+			if err = json.Unmarshal(b, &batch); err != nil {
+				h.setParamError("requests", "type", "array")
+				return h.Respond(errValidation)
+			}
+		}
+
+		if len(batch.Requests) == 0 || len(batch.Requests) > opts.MaxRequests {
+			h.setParamError("requests", "range", strconv.Itoa(opts.MaxRequests))
+		}
+
+		if batch.Transaction && !opts.AllowTransaction {
+			h.setParamError("transaction", "allowed", "")
+		}
+
+		for i, r := range batch.Requests {
+			path := strings.SplitN(r.Path, "?", 2)[0]
+			if !strings.HasPrefix(path, "/") || path == c.Path() {
+				h.setParamError(fmt.Sprintf("requests[%d].path", i), "invalid", "")
+			} else if batch.Transaction && !transactionalRoute(strings.ToUpper(r.Method), path) {
+				h.setParamError(fmt.Sprintf("requests[%d].path", i), "transactional", "")
+			}
+		}
+
+		if len(h.Response.Errors) > 0 {
+			return h.Respond(errValidation)
+		}
+
+		parent := batchParent{host: c.Request().Host(), headers: map[string]string{}}
+		for _, k := range inheritedHeaders {
+			if v := c.Request().Header().Get(k); v != "" {
+				parent.headers[k] = v
+			}
+		}
+
+		var results []BatchResult
+		if batch.Transaction {
+			results, err = runBatchTransaction(parent, batch.Requests)
+		} else {
+			results = runBatch(parent, batch.Requests, *batch.Parallel, opts.Concurrency)
+		}
+
+		if err != nil {
+			h.Response.SetCode(response.StatusBadRequest)
+			h.Response.SetMessage(err.Error())
+			h.Response.SetData(results)
+
+			return response.Render(c, h.Response.Code, h.Response)
+		}
+
+		h.Response.SetData(results)
+
+		return h.Respond(nil)
+	})
+}
+
+// Transactional marks the route of method and path on g as writing through
+// DBOf, so it is accepted in transactional batches. A nil g marks a route
+// of Echo itself. For example
+//
+//	g.POST("/orders", createOrder)
+//	cuxs.Transactional(g, echo.POST, "/orders")
+func Transactional(g *echo.Group, method string, path string) {
+	transactionalMutex.Lock()
+	transactionalRoutes[method+" "+groupPrefix(g)+path] = true
+	transactionalMutex.Unlock()
+}
+
+// transactionalRoute reports whether the route serving method and path
+// is marked with Transactional.
+func transactionalRoute(method string, path string) bool {
+	c := Echo.NewContext(nil, nil)
+	Echo.Router().Find(method, path, c)
+
+	transactionalMutex.RLock()
+	defer transactionalMutex.RUnlock()
+
+	return transactionalRoutes[method+" "+c.Path()]
+}
+
+// groupPrefix returns the path prefix of the group, which echo does not export.
+func groupPrefix(g *echo.Group) string {
+	if g == nil {
+		return ""
+	}
+
+	return reflect.ValueOf(g).Elem().FieldByName("prefix").String()
+}
+
+// DBOf returns the database transaction of the batch serving the request,
+// or ORM when the request is not part of a transactional batch. Handlers
+// using it should be marked with Transactional.
+func DBOf(c echo.Context) *gorm.DB {
+	if tx, ok := batchTx(c); ok {
+		return tx
+	}
+
+	return ORM()
+}
+
+func batchTx(c echo.Context) (*gorm.DB, bool) {
+	if r, ok := c.Request().(*standard.Request); ok {
+		tx, ok := r.Request.Context().Value(batchContextKey{}).(*gorm.DB)
+		return tx, ok
+	}
+
+	return nil, false
+}
+
+func runBatch(parent batchParent, requests []BatchRequest, parallel bool, concurrency int) []BatchResult {
+	results := make([]BatchResult, len(requests))
+	if !parallel {
+		for i, r := range requests {
+			results[i] = dispatch(parent, r, context.Background())
+		}
+
+		return results
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, r := range requests {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, r BatchRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results[i] = dispatch(parent, r, context.Background())
+		}(i, r)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// runBatchTransaction runs the requests one by one in a transaction,
+// every change is rolled back when a request fails.
+func runBatchTransaction(parent batchParent, requests []BatchRequest) ([]BatchResult, error) {
+	tx := ORM().Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	ctx := context.WithValue(context.Background(), batchContextKey{}, tx)
+	results := make([]BatchResult, len(requests))
+	for i, r := range requests {
+		results[i] = dispatch(parent, r, ctx)
+		if results[i].Status >= 400 {
+			tx.Rollback()
+			return results[:i+1], fmt.Errorf("request %d failed, batch rolled back", i)
+		}
+	}
+
+	return results, tx.Commit().Error
+}
+
+// dispatch serves the sub request through the Echo router.
+func dispatch(parent batchParent, r BatchRequest, ctx context.Context) BatchResult {
+	var body []byte
+	if len(r.Body) > 0 && string(r.Body) != "null" {
+		body = r.Body
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(r.Method), r.Path, bytes.NewReader(body))
+	if err != nil {
+		return BatchResult{Status: response.StatusBadRequest}
+	}
+
+	req = req.WithContext(ctx)
+	req.Host = parent.host
+	for k, v := range parent.headers {
+		req.Header.Set(k, v)
+	}
+
+	if body != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+
+	// the results are embedded as json
+	req.Header.Set(response.HeaderAccept, response.MIMEJSON)
+
+	rec := httptest.NewRecorder()
+	Echo.ServeHTTP(standard.NewRequest(req, Echo.Logger()), standard.NewResponse(rec, Echo.Logger()))
+
+	res := BatchResult{Status: rec.Code}
+	if b := bytes.TrimSpace(rec.Body.Bytes()); json.Valid(b) {
+		res.Body = b
+	}
+
+	return res
+}
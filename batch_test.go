@@ -0,0 +1,122 @@
+package cuxs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+)
+
+type batchModel struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+var batchRoutes sync.Once
+
+func newTestBatch(t *testing.T) *gorm.DB {
+	newTestContext("GET", "/", "", "")
+
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "batch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.AutoMigrate(&batchModel{})
+	Orm[Config.DatabaseConfig.DBName] = db
+	t.Cleanup(func() {
+		delete(Orm, Config.DatabaseConfig.DBName)
+		db.Close()
+	})
+
+	batchRoutes.Do(func() {
+		g := Echo.Group("/batch-test")
+		Resource(g, "/models", &batchModel{}, ResourceOptions{})
+		g.POST("/plain", func(c echo.Context) error {
+			return c.NoContent(204)
+		})
+
+		// the same handlers and closures run on routes that are not transactional
+		Resource(g, "/own", &batchModel{}, ResourceOptions{DB: ORM})
+		for _, p := range []string{"/closure-a", "/closure-b"} {
+			g.POST(p, func(c echo.Context) error {
+				return c.NoContent(204)
+			})
+		}
+		Transactional(g, echo.POST, "/closure-a")
+		Batch("/batch-test/batch", BatchOptions{AllowTransaction: true})
+	})
+
+	return db
+}
+
+func batchResults(t *testing.T, body string) (int, []BatchResult) {
+	code, res := serveTest(Echo, "POST", "/batch-test/batch", body)
+
+	var results []BatchResult
+	b, _ := json.Marshal(res["data"])
+	json.Unmarshal(b, &results)
+
+	return code, results
+}
+
+func TestBatchParallel(t *testing.T) {
+	db := newTestBatch(t)
+
+	var requests []string
+	for i := 0; i < 20; i++ {
+		requests = append(requests, fmt.Sprintf(`{"method":"POST","path":"/batch-test/models","body":{"name":"n%d"}}`, i))
+	}
+
+	code, results := batchResults(t, `{"parallel":true,"requests":[`+strings.Join(requests, ",")+`]}`)
+	if code != 200 || len(results) != 20 {
+		t.Fatalf("got %d %v", code, results)
+	}
+
+	for i, r := range results {
+		if r.Status != 201 && r.Status != 200 {
+			t.Errorf("request %d: status %d %s", i, r.Status, r.Body)
+		}
+	}
+
+	var n int
+	if db.Model(&batchModel{}).Count(&n); n != 20 {
+		t.Errorf("%d rows, want 20", n)
+	}
+}
+
+func TestBatchTransaction(t *testing.T) {
+	db := newTestBatch(t)
+
+	tests := []struct {
+		body string
+		code int
+		rows int
+	}{
+		{`{"transaction":true,"requests":[{"method":"POST","path":"/batch-test/models","body":{"name":"a"}},{"method":"POST","path":"/batch-test/models","body":{"name":"b"}}]}`, 200, 2},
+		{`{"transaction":true,"requests":[{"method":"POST","path":"/batch-test/models","body":{"name":"c"}},{"method":"POST","path":"/batch-test/models","body":{}}]}`, 400, 2},
+		{`{"transaction":true,"requests":[{"method":"POST","path":"/batch-test/plain"}]}`, 422, 2},
+		{`[{"method":"POST","path":"/batch-test/plain"}]`, 200, 2},
+		{`{"transaction":true,"requests":[{"method":"POST","path":"/batch-test/own","body":{"name":"d"}}]}`, 422, 2},
+		{`{"transaction":true,"requests":[{"method":"POST","path":"/batch-test/closure-a"}]}`, 200, 2},
+		{`{"transaction":true,"requests":[{"method":"POST","path":"/batch-test/closure-b"}]}`, 422, 2},
+		{`[{"method":"POST","path":"/batch-test/batch"}]`, 422, 2},
+	}
+
+	for _, tt := range tests {
+		code, results := batchResults(t, tt.body)
+		if code != tt.code {
+			t.Errorf("%s: got %d %v, want %d", tt.body, code, results, tt.code)
+		}
+
+		var n int
+		if db.Model(&batchModel{}).Count(&n); n != tt.rows {
+			t.Errorf("%s: %d rows, want %d", tt.body, n, tt.rows)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package cuxs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	cerrors "github.com/qasico/cuxs/errors"
+)
+
+var errValidation = errors.New("Validation Failed")
+
+// bind fills req from the request body, then the query string and path
+// params of the fields tagged with query and param. Malformed bodies are
+// returned as bad request errors, type mismatches and unknown fields in
+// strict mode are added as validation errors.
+func (h *Handler) bind(req interface{}) error {
+	r := h.Context.Request()
+	if err := h.readBody(); err != nil {
+		return err
+	}
+
+	if ct := r.Header().Get(echo.HeaderContentType); len(bytes.TrimSpace(h.body)) > 0 {
+		switch {
+		case strings.HasPrefix(ct, MIMEJSONPatch):
+			// the operations are applied by UpdateModel
+		case strings.HasPrefix(ct, echo.MIMEApplicationJSON), strings.HasPrefix(ct, MIMEMergePatch):
+			if err := h.bindJSON(req); err != nil {
+				return err
+			}
+		default:
+			if err := h.Context.Bind(req); err != nil {
+				return cerrors.Wrap(err, 400, "invalid_body", "Malformed request body")
+			}
+		}
+	}
+
+	h.bindValues(req, "query", h.Context.QueryParam)
+	h.bindValues(req, "param", h.Context.Param)
+
+	if len(h.Response.Errors) > 0 {
+		return errValidation
+	}
+
+	return nil
+}
+
+// readBody keeps the request body for the decoders and
+// rewinds it for the handlers reading it again.
+func (h *Handler) readBody() error {
+	r := h.Context.Request()
+	if b := r.Body(); b != nil {
+		body, err := ioutil.ReadAll(b)
+		if err != nil {
+			return cerrors.Wrap(err, 400, "invalid_body", "Cannot read request body")
+		}
+
+		h.body = body
+		r.SetBody(bytes.NewReader(body))
+	}
+
+	return nil
+}
+
+func (h *Handler) bindJSON(req interface{}) error {
+	return h.decodeJSON(h.body, req)
+}
+
+// decodeJSON decodes body into v, adding type mismatches and unknown
+// fields in strict mode as validation errors.
+func (h *Handler) decodeJSON(body []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(body))
+	if h.Strict || Config.StrictBind {
+		d.DisallowUnknownFields()
+	}
+
+	err := d.Decode(v)
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *json.SyntaxError:
+		return cerrors.New(400, "malformed_json", fmt.Sprintf("Malformed json at offset %d", e.Offset))
+	case *json.UnmarshalTypeError:
+		h.setRuleError(e.Field, "type", typeName(e.Type), reflect.Invalid)
+		return nil
+	}
+
+	// json: unknown field "name"
+	if m := strings.TrimPrefix(err.Error(), "json: unknown field "); m != err.Error() {
+		h.setRuleError(strings.Trim(m, `"`), "unknown", "", reflect.Invalid)
+		return nil
+	}
+
+	return cerrors.Wrap(err, 400, "malformed_json", "Malformed json body")
+}
+
+// bindValues sets the struct fields tagged with tag from the
+// non empty values returned by get for the tag name.
+func (h *Handler) bindValues(req interface{}, tag string, get func(string) string) {
+	v := reflect.Indirect(reflect.ValueOf(req))
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get(tag)
+		if name == "" || name == "-" {
+			continue
+		}
+
+		s := get(name)
+		f := v.Field(i)
+		if s == "" || !f.CanSet() {
+			continue
+		}
+
+		if !setString(f, s) {
+			h.setRuleError(name, "type", typeName(f.Type()), reflect.Invalid)
+		}
+	}
+}
+
+// setString converts s to the kind of f, slices are comma separated.
+func setString(f reflect.Value, s string) bool {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return false
+		}
+
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return false
+		}
+
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return false
+		}
+
+		f.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false
+		}
+
+		f.SetBool(b)
+	case reflect.Ptr:
+		p := reflect.New(f.Type().Elem())
+		if !setString(p.Elem(), s) {
+			return false
+		}
+
+		f.Set(p)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		sl := reflect.MakeSlice(f.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if !setString(sl.Index(i), p) {
+				return false
+			}
+		}
+
+		f.Set(sl)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// typeName returns the json type expected for t.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+
+	return "object"
+}
@@ -0,0 +1,44 @@
+package cuxs
+
+import (
+	"reflect"
+	"testing"
+)
+
+type bindRequest struct {
+	Name  string `json:"name"`
+	Age   int    `json:"age"`
+	Page  int    `query:"page"`
+	Owner string `param:"owner"`
+}
+
+func TestBindErrors(t *testing.T) {
+	tests := []struct {
+		target string
+		body   string
+		strict bool
+		errors []string
+	}{
+		{"/items", `{"name":"a","age":1}`, false, nil},
+		{"/items", `{"name":1}`, false, []string{"name must be a valid string"}},
+		{"/items", `{"age":"x"}`, false, []string{"age must be a valid integer"}},
+		{"/items?page=x", `{}`, false, []string{"page must be a valid integer"}},
+		{"/items", `{"other":1}`, false, nil},
+		{"/items", `{"other":1}`, true, []string{"other is not a known field"}},
+	}
+
+	for _, tt := range tests {
+		c, _ := newTestContext("POST", tt.target, "application/json", tt.body)
+		h := &Handler{Strict: tt.strict}
+		h.Prepare(c, new(bindRequest))
+
+		var errors []string
+		for _, e := range h.Response.Errors {
+			errors = append(errors, e.Field+" "+e.Message)
+		}
+
+		if !reflect.DeepEqual(errors, tt.errors) {
+			t.Errorf("%s %s: got %v, want %v", tt.target, tt.body, errors, tt.errors)
+		}
+	}
+}
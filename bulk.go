@@ -0,0 +1,271 @@
+package cuxs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	cerrors "github.com/qasico/cuxs/errors"
+	"github.com/qasico/cuxs/idcodec"
+	"github.com/qasico/cuxs/response"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+// DefaultBulkItems limits the items of a bulk request.
+var DefaultBulkItems = 100
+
+// Bulk runs an action over the items of a json array request. Errors of
+// an item are reported indexed by the item, as items[3].email.
+type Bulk struct {
+	// Partial commits the items that succeed and reports the failed ones,
+	// otherwise a single failure rolls back the whole bulk.
+	Partial bool
+
+	handler *Handler
+	items   reflect.Value
+	raw     []json.RawMessage
+	failed  map[int]bool
+}
+
+// BindBulk reads the json array body into items, a pointer to a slice,
+// then decodes the id codec fields and validates every struct element.
+// It returns errValidation when an item is invalid, the bulk can still
+// be run in partial mode without the invalid items.
+func (h *Handler) BindBulk(items interface{}, partial bool) (b *Bulk, err error) {
+	b = &Bulk{Partial: partial, handler: h, items: reflect.ValueOf(items).Elem(), failed: map[int]bool{}}
+	if err = h.readBody(); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(h.body, &b.raw); err != nil {
+		return b, cerrors.New(400, "malformed_json", "Request body must be a json array")
+	}
+
+	if len(b.raw) == 0 || len(b.raw) > DefaultBulkItems {
+		h.setParamError("items", "range", strconv.Itoa(DefaultBulkItems))
+		return b, errValidation
+	}
+
+	b.items.Set(reflect.MakeSlice(b.items.Type(), len(b.raw), len(b.raw)))
+	for i, raw := range b.raw {
+		item := b.items.Index(i).Addr().Interface()
+		n := len(h.Response.Errors)
+		if err = h.bindItem(raw, item); err != nil && err != errValidation {
+			return
+		}
+
+		if len(h.Response.Errors) > n {
+			b.fail(i, n)
+		}
+	}
+
+	if len(b.failed) > 0 {
+		return b, errValidation
+	}
+
+	return b, nil
+}
+
+func (h *Handler) bindItem(raw json.RawMessage, item interface{}) error {
+	if err := h.decodeJSON(raw, item); err != nil {
+		return err
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(item))
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if err := idcodec.DecodeFields(item); err != nil {
+		if fe, ok := err.(*idcodec.FieldError); ok {
+			h.setRuleError(fe.Field, "idcodec", "", reflect.String)
+		}
+
+		return nil
+	}
+
+	if err := h.validate(v.Addr().Interface()); err != nil {
+		h.ValidationError(err.(validator.ValidationErrors))
+	}
+
+	return nil
+}
+
+// Len returns the number of items.
+func (b *Bulk) Len() int {
+	return len(b.raw)
+}
+
+// Raw returns the json of the item i as sent by the client.
+func (b *Bulk) Raw(i int) json.RawMessage {
+	return b.raw[i]
+}
+
+// Failed reports whether the item i failed.
+func (b *Bulk) Failed(i int) bool {
+	return b.failed[i]
+}
+
+// fail marks the item i failed and indexes the errors added since n.
+func (b *Bulk) fail(i int, n int) {
+	b.failed[i] = true
+
+	errs := b.handler.Response.Errors
+	for j := n; j < len(errs); j++ {
+		if errs[j].Field == "" {
+			errs[j].Field = fmt.Sprintf("items[%d]", i)
+		} else {
+			errs[j].Field = fmt.Sprintf("items[%d].%s", i, errs[j].Field)
+		}
+	}
+}
+
+// Run calls fn for every valid item in a transaction of db, or of the
+// request database when db is nil. In partial mode every item runs in a
+// savepoint so a failed item is rolled back alone, otherwise the first
+// failure rolls back the transaction. Item errors below 500, including
+// the constraint violations mapped to 409, are reported on the item,
+// server errors such as a lost connection abort the bulk.
+func (b *Bulk) Run(db *gorm.DB, fn func(tx *gorm.DB, i int) error) (err error) {
+	if len(b.failed) > 0 && !b.Partial {
+		return errValidation
+	}
+
+	h := b.handler
+	tx, ok := batchTx(h.Context)
+	if ok && db != nil {
+		return errBatchTransaction
+	}
+
+	if !ok {
+		if db == nil {
+			db = DBOf(h.Context)
+		}
+
+		if tx = db.Begin(); tx.Error != nil {
+			return dbError(tx.Error)
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				tx.Rollback()
+				panic(p)
+			}
+
+			if err != nil {
+				tx.Rollback()
+			} else if err = tx.Commit().Error; err != nil {
+				err = dbError(err)
+			}
+		}()
+	}
+
+	for i := range b.raw {
+		if b.failed[i] {
+			continue
+		}
+
+		if err = b.runItem(tx, i, fn); err != nil {
+			return err
+		}
+	}
+
+	if len(b.failed) > 0 && !b.Partial {
+		return errValidation
+	}
+
+	return nil
+}
+
+func (b *Bulk) runItem(tx *gorm.DB, i int, fn func(tx *gorm.DB, i int) error) error {
+	if b.Partial {
+		if err := tx.Exec("SAVEPOINT cuxs_bulk").Error; err != nil {
+			return dbError(err)
+		}
+	}
+
+	n := len(b.handler.Response.Errors)
+	err := fn(tx, i)
+	if err != nil && err != errValidation {
+		e, ok := cerrors.As(err)
+		if !ok {
+			e, _ = cerrors.As(dbError(err))
+		}
+
+		if e.Status >= response.StatusInternalServerError {
+			return err
+		}
+
+		b.handler.Response.Errors = append(b.handler.Response.Errors, response.ErrorValidation{Message: e.Message, Rule: e.Code})
+	}
+
+	if err == nil {
+		if b.Partial {
+			return dbError(tx.Exec("RELEASE SAVEPOINT cuxs_bulk").Error)
+		}
+
+		return nil
+	}
+
+	b.fail(i, n)
+	if b.Partial {
+		return dbError(tx.Exec("ROLLBACK TO SAVEPOINT cuxs_bulk").Error)
+	}
+
+	return errValidation
+}
+
+// Respond writes data, a slice of the results of the items or nil for
+// the bound items, with nulls in place of the failed ones so data[3]
+// matches the errors of items[3]. A bulk with failed and succeeded
+// items responds with multi status.
+func (b *Bulk) Respond(data interface{}, err error) error {
+	h := b.handler
+	if err != nil || len(b.failed) == len(b.raw) {
+		if err == nil {
+			err = errValidation
+		}
+
+		return h.Respond(err)
+	}
+
+	items := b.items
+	if data != nil {
+		items = reflect.ValueOf(data)
+	}
+
+	res := make([]interface{}, len(b.raw))
+	for i := range res {
+		if v := items.Index(i); !b.failed[i] {
+			if v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+
+			if v.Kind() != reflect.Ptr && v.CanAddr() {
+				v = v.Addr()
+			}
+
+			res[i] = v.Interface()
+		}
+	}
+
+	if err := idcodec.EncodeFields(res); err != nil {
+		return h.Respond(cerrors.Internal(err))
+	}
+
+	h.Response.Data = res
+	h.Response.Status = response.StatusSuccess
+	h.Response.Message = nil
+	h.Response.SetCode(response.StatusOK)
+	if len(b.failed) > 0 {
+		h.Response.SetCode(response.StatusMultiStatus)
+	}
+
+	return response.Render(h.Context, h.Response.Code, h.Response)
+}
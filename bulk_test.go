@@ -0,0 +1,38 @@
+package cuxs
+
+import "testing"
+
+func TestResourceBulk(t *testing.T) {
+	e, db := newTestResource(t, ResourceOptions{Bulk: true})
+	db.Model(&resourceModel{}).AddUniqueIndex("idx_resource_email", "email")
+
+	tests := []struct {
+		method, target, body string
+		code, rows           int
+	}{
+		{"POST", "/models/bulk", `[{"name":"b","email":"b@x.com"},{"name":"c","email":"a@x.com"}]`, 422, 1},
+		{"POST", "/models/bulk?mode=partial", `[{"name":"b","email":"b@x.com"},{"name":"c","email":"a@x.com"}]`, 207, 2},
+		{"POST", "/models/bulk?mode=partial", `[{"name":"c","email":"a@x.com"},{"name":"d"}]`, 422, 2},
+		{"PUT", "/models/bulk", `[{"id":1,"name":"z","email":"z@x.com"}]`, 200, 2},
+		{"DELETE", "/models/bulk?mode=partial", `[1,9]`, 207, 1},
+	}
+
+	for _, tt := range tests {
+		if code, res := serveTest(e, tt.method, tt.target, tt.body); code != tt.code {
+			t.Errorf("%s %s %s: got %d %v, want %d", tt.method, tt.target, tt.body, code, res, tt.code)
+		}
+
+		var n int
+		if db.Model(&resourceModel{}).Count(&n); n != tt.rows {
+			t.Errorf("%s %s %s: %d rows, want %d", tt.method, tt.target, tt.body, n, tt.rows)
+		}
+
+		if tt.method == "PUT" {
+			got := resourceModel{}
+			db.First(&got, 1)
+			if want := (resourceModel{Id: 1, Name: "z", Email: "z@x.com", PasswordHash: "h"}); got != want {
+				t.Errorf("bulk PUT saved %+v, want %+v", got, want)
+			}
+		}
+	}
+}
@@ -1,12 +1,18 @@
 package cuxs
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"strconv"
 
 	"github.com/joho/godotenv"
+	"github.com/qasico/cuxs/errors"
+	"github.com/qasico/cuxs/idcodec"
 	"github.com/qasico/cuxs/log"
+	"github.com/qasico/cuxs/response"
 )
 
 const (
@@ -22,6 +28,7 @@ type (
 		EnableHTTPS   bool
 		HTTPSCertFile string
 		HTTPSKeyFile  string
+		RedirectAddr  string
 	}
 
 	DatabaseConfig struct {
@@ -47,12 +54,22 @@ type (
 		Runmode          string
 		ServerName       string
 		ResponseType     string
+		ErrorFormat      string
+		Locale           string
+		LocalePath       string
+		ProblemTypeURI   string
 		JwtHash          string
+		PolicyFile       string
+		IDCodec          string
+		IDSecret         string
 		RecoverPanic     bool
 		CopyRequestBody  bool
+		StrictBind       bool
 		EnableErrorsShow bool
 		EnableGzip       bool
 		MaxMemory        int
+		DefaultPerPage   int
+		MaxPerPage       int
 		DatabaseConfig   DatabaseConfig
 		ServerConfig     ServerConfig
 		RedisConfig      RedisConfig
@@ -73,12 +90,22 @@ func init() {
 	Config.Runmode = Config.getString("APP_RUNMODE", DEFAULT_RUNMODE)
 	Config.ServerName = Config.getString("APP_NAME", "cuxs "+VERSION)
 	Config.ResponseType = Config.getString("APP_RESPONSE_TYPE", "json")
+	Config.Locale = Config.getString("APP_LOCALE", "en")
+	Config.LocalePath = Config.getString("APP_LOCALE_PATH", "")
+	Config.ErrorFormat = Config.getString("APP_ERROR_FORMAT", response.ErrorFormatDefault)
+	Config.ProblemTypeURI = Config.getString("APP_PROBLEM_URI", "")
 	Config.JwtHash = Config.getString("APP_JWT_SECRET", "123rty890")
+	Config.PolicyFile = Config.getString("APP_POLICY", "")
+	Config.IDCodec = Config.getString("APP_ID_CODEC", "hashids")
+	Config.IDSecret = Config.getString("APP_ID_SECRET", idSecret(Config.JwtHash))
 	Config.RecoverPanic = Config.getBool("APP_RECOVER", true)
 	Config.CopyRequestBody = Config.getBool("APP_CBODY", true)
+	Config.StrictBind = Config.getBool("APP_STRICT_BIND", false)
 	Config.EnableErrorsShow = Config.getBool("APP_DEBUG", false)
 	Config.EnableGzip = Config.getBool("APP_GZIP", true)
 	Config.MaxMemory = Config.getInt("APP_MMEMORY", 1<<26)
+	Config.DefaultPerPage = Config.getInt("APP_PERPAGE", 10)
+	Config.MaxPerPage = Config.getInt("APP_MAX_PERPAGE", 100)
 
 	Config.DatabaseConfig.Engine = Config.getString("DB_ENGINE", "postgres")
 	Config.DatabaseConfig.ServerHost = Config.getString("DB_HOST", "127.0.0.1")
@@ -95,10 +122,31 @@ func init() {
 	Config.ServerConfig.EnableHTTPS = Config.getBool("SERVER_SSL", false)
 	Config.ServerConfig.HTTPSCertFile = Config.getString("SERVER_CERT", "")
 	Config.ServerConfig.HTTPSKeyFile = Config.getString("SERVER_KEY", "")
+	Config.ServerConfig.RedirectAddr = Config.getString("SERVER_REDIRECT", "")
 
 	Config.RedisConfig.Network = Config.getString("REDIS_NETWORK", "")
 	Config.RedisConfig.Address = Config.getString("REDIS_ADDRESS", "")
 	Config.RedisConfig.Password = Config.getString("REDIS_PASS", "")
+
+	if err := response.SetDefaultType(Config.ResponseType); err != nil {
+		log.Warnf("%s, using json", err.Error())
+	}
+
+	if Config.LocalePath != "" {
+		if err := LoadCatalogs(Config.LocalePath); err != nil {
+			log.Warnf("Cannot load locale catalogs, %s", err.Error())
+		}
+	}
+
+	response.ErrorFormat = Config.ErrorFormat
+	errors.ShowCause = Config.EnableErrorsShow
+	response.ProblemTypeURI = Config.ProblemTypeURI
+
+	if codec, err := idcodec.New(Config.IDCodec, Config.IDSecret); err != nil {
+		log.Warnf("%s, using default id codec", err.Error())
+	} else {
+		idcodec.SetDefault(codec)
+	}
 }
 
 // Load .env file in app directory to use for config param
@@ -153,3 +201,13 @@ func (c *AppConfig) getBool(key string, defaultValue bool) (val bool) {
 
 	return defaultValue
 }
+
+// idSecret derives the default id codec secret from the jwt secret, the
+// hashids salt can be recovered from encoded ids so it must not be the
+// jwt signing key itself.
+func idSecret(jwtSecret string) string {
+	m := hmac.New(sha256.New, []byte(jwtSecret))
+	m.Write([]byte("idcodec"))
+
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,25 @@
+package cuxs
+
+import "testing"
+
+func TestIDSecret(t *testing.T) {
+	tests := []string{"123rty890", "another secret", ""}
+
+	seen := map[string]string{}
+	for _, jwt := range tests {
+		s := idSecret(jwt)
+		if s == jwt || s == "" {
+			t.Errorf("%q: derived %q", jwt, s)
+		}
+
+		if s != idSecret(jwt) {
+			t.Errorf("%q: derivation is not stable", jwt)
+		}
+
+		if prev, ok := seen[s]; ok {
+			t.Errorf("%q and %q derive the same secret", prev, jwt)
+		}
+
+		seen[s] = jwt
+	}
+}
@@ -0,0 +1,31 @@
+package cuxs
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
+)
+
+var testEcho sync.Once
+
+// newTestContext returns an echo context of a request with body.
+func newTestContext(method string, target string, contentType string, body string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+
+	testEcho.Do(func() {
+		if Echo == nil {
+			NewEcho()
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	c := Echo.NewContext(standard.NewRequest(req, Echo.Logger()), standard.NewResponse(rec, Echo.Logger()))
+
+	return c, rec
+}
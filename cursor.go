@@ -0,0 +1,240 @@
+package cuxs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	cerrors "github.com/qasico/cuxs/errors"
+)
+
+// Cursor is the decoded position of a keyset page,
+// Values holds the sort keys of the row at the page boundary
+// and Keys the hash of the keyset they belong to.
+type Cursor struct {
+	Values []interface{} `json:"v"`
+	Prev   bool          `json:"p,omitempty"`
+	Keys   string        `json:"k"`
+}
+
+var (
+	errInvalidCursor  = errors.New("invalid cursor")
+	errCursorMismatch = cerrors.BadRequest("invalid_cursor", "Cursor does not match the sort of the request")
+)
+
+// EncodeCursor returns the opaque cursor signed with JwtKey.
+func EncodeCursor(c Cursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	p := base64.RawURLEncoding.EncodeToString(b)
+
+	return p + "." + signCursor(p), nil
+}
+
+// DecodeCursor verifies the signature of the opaque cursor and decodes it.
+func DecodeCursor(s string) (*Cursor, error) {
+	i := strings.LastIndex(s, ".")
+	if i < 0 || !hmac.Equal([]byte(s[i+1:]), []byte(signCursor(s[:i]))) {
+		return nil, errInvalidCursor
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s[:i])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	// keep numbers as json.Number so large ids are not rounded
+	c := new(Cursor)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(c); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return c, nil
+}
+
+func signCursor(payload string) string {
+	m := hmac.New(sha256.New, JwtKey())
+	m.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// keysetHash identifies the table, columns and directions of a keyset,
+// so a cursor is not applied to another sort or resource.
+func keysetHash(table string, sorts []SortField) string {
+	m := sha256.New()
+	m.Write([]byte(table))
+	for _, o := range sorts {
+		fmt.Fprintf(m, ",%s:%t", o.Column, o.Desc)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil)[:12])
+}
+
+// parseCursor enables the cursor mode when the cursor query string is
+// present, an empty cursor requests the first page.
+func (h *Handler) parseCursor(qs map[string][]string) {
+	param, ok := qs["cursor"]
+	if !ok {
+		return
+	}
+
+	h.QueryParam.CursorMode = true
+	h.QueryParam.Offset = 0
+
+	if param[0] != "" {
+		c, err := DecodeCursor(param[0])
+		if err != nil {
+			h.setParamError("cursor", "invalid", "")
+			return
+		}
+
+		h.QueryParam.Cursor = c
+	}
+}
+
+// applyKeyset orders db by the sort keys, completed with the primary key
+// to be unique, and filters the rows after the cursor position.
+// One extra row is fetched to know if there is another page.
+func (q *QueryParam) applyKeyset(db *gorm.DB, s *modelSchema) *gorm.DB {
+	pk := s.scope.PrimaryKey()
+	sorts := make([]SortField, 0, len(q.Sorts)+1)
+	unique := false
+	for _, o := range q.Sorts {
+		column, ok := s.columns[o.Column]
+		if !ok {
+			db.AddError(&ParamError{Param: "sort", Field: o.Field})
+			continue
+		}
+
+		// nulls can not be compared in the keyset predicate
+		o.Column, o.Nulls = column, ""
+		sorts = append(sorts, o)
+		unique = unique || column == pk
+	}
+
+	if !unique {
+		sorts = append(sorts, SortField{Field: pk, Column: pk})
+	}
+
+	q.keyset = sorts
+	q.schema = s
+
+	prev := q.Cursor != nil && q.Cursor.Prev
+	if q.Cursor != nil {
+		if len(q.Cursor.Values) != len(sorts) || q.Cursor.Keys != keysetHash(s.scope.TableName(), sorts) {
+			db.AddError(errCursorMismatch)
+			return db
+		}
+
+		where, values := keysetPredicate(s.scope, sorts, q.Cursor.Values, prev)
+		db = db.Where(where, values...)
+	}
+
+	for _, o := range sorts {
+		if prev {
+			o.Desc = !o.Desc
+		}
+
+		db = db.Order(o.Order(s.scope.Quote(o.Column)))
+	}
+
+	return db.Limit(q.Limit + 1)
+}
+
+// keysetPredicate builds (a > ?) OR (a = ? AND b > ?) ... over the sort keys.
+func keysetPredicate(scope *gorm.Scope, sorts []SortField, values []interface{}, prev bool) (string, []interface{}) {
+	var ors []string
+	var args []interface{}
+	for i, o := range sorts {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, scope.Quote(sorts[j].Column)+" = ?")
+			args = append(args, values[j])
+		}
+
+		op := ">"
+		if o.Desc != prev {
+			op = "<"
+		}
+
+		ands = append(ands, scope.Quote(o.Column)+" "+op+" ?")
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return strings.Join(ors, " OR "), args
+}
+
+// SetCursors trims the extra row fetched by Apply in cursor mode from the
+// pointer to slice out, restores the order of a previous page and builds
+// NextCursor and PrevCursor from the rows at the page boundaries.
+func (q *QueryParam) SetCursors(out interface{}) error {
+	if q == nil || !q.CursorMode || q.schema == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("cursor result must be a pointer to slice")
+	}
+
+	v = v.Elem()
+	more := v.Len() > q.Limit
+	if more {
+		v.Set(v.Slice(0, q.Limit))
+	}
+
+	prev := q.Cursor != nil && q.Cursor.Prev
+	if prev {
+		for i, j := 0, v.Len()-1; i < j; i, j = i+1, j-1 {
+			a, b := v.Index(i).Interface(), v.Index(j).Interface()
+			v.Index(i).Set(reflect.ValueOf(b))
+			v.Index(j).Set(reflect.ValueOf(a))
+		}
+	}
+
+	if v.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if more || prev {
+		if q.NextCursor, err = q.boundary(v.Index(v.Len()-1), false); err != nil {
+			return err
+		}
+	}
+
+	if (prev && more) || (!prev && q.Cursor != nil) {
+		q.PrevCursor, err = q.boundary(v.Index(0), true)
+	}
+
+	return err
+}
+
+func (q *QueryParam) boundary(row reflect.Value, prev bool) (string, error) {
+	row = reflect.Indirect(row)
+	values := make([]interface{}, 0, len(q.keyset))
+	for _, o := range q.keyset {
+		f := row.FieldByName(q.schema.fields[o.Column])
+		if !f.IsValid() {
+			return "", fmt.Errorf("cursor field %s not found", o.Column)
+		}
+
+		values = append(values, f.Interface())
+	}
+
+	return EncodeCursor(Cursor{Values: values, Prev: prev, Keys: keysetHash(q.schema.scope.TableName(), q.keyset)})
+}
@@ -0,0 +1,66 @@
+package cuxs
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	valid, err := EncodeCursor(Cursor{Values: []interface{}{"a", 1}, Keys: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := strings.LastIndex(valid, ".")
+	tests := []struct {
+		cursor string
+		err    error
+	}{
+		{valid, nil},
+		{valid[:i], errInvalidCursor},
+		{valid + "x", errInvalidCursor},
+		{"e30" + valid[i:], errInvalidCursor},
+		{"!" + "." + signCursor("!"), errInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		c, err := DecodeCursor(tt.cursor)
+		if err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.cursor, err, tt.err)
+		}
+
+		if err == nil && (c.Keys != "k" || len(c.Values) != 2) {
+			t.Errorf("%s: decoded %+v", tt.cursor, c)
+		}
+	}
+}
+
+func TestResourceCursor(t *testing.T) {
+	e, db := newTestResource(t, ResourceOptions{Sorts: SortRules{"name": "name", "email": "email"}})
+	db.Create(&resourceModel{Id: 2, Name: "b", Email: "b@x.com"})
+	db.Create(&resourceModel{Id: 3, Name: "c", Email: "c@x.com"})
+
+	code, res := serveTest(e, "GET", "/models?cursor=&per_page=1&sort=name", "")
+	next, _ := res["next_cursor"].(string)
+	if code != 200 || next == "" {
+		t.Fatalf("first page: got %d %v", code, res)
+	}
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"sort=name", 200},
+		{"sort=-name", 400},
+		{"sort=email", 400},
+		{"", 400},
+	}
+
+	for _, tt := range tests {
+		target := "/models?per_page=1&cursor=" + url.QueryEscape(next) + "&" + tt.query
+		if code, res := serveTest(e, "GET", target, ""); code != tt.code {
+			t.Errorf("%s: got %d %v, want %d", tt.query, code, res, tt.code)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package cuxs
 
 import (
 	"github.com/labstack/echo"
-	"github.com/labstack/echo/engine/fasthttp"
 	"github.com/qasico/cuxs/log"
 	"github.com/qasico/cuxs/middleware"
 
@@ -36,8 +35,28 @@ func Run() {
 		listRoutes()
 	}
 
-	log.Infof("Server running on %s", Config.ServerConfig.HTTPAddr)
-	Echo.Run(fasthttp.WithTLS(Config.ServerConfig.HTTPAddr, Config.ServerConfig.HTTPSCertFile, Config.ServerConfig.HTTPSKeyFile))
+	if Config.PolicyFile != "" {
+		if err := LoadPolicy(Config.PolicyFile); err != nil {
+			log.Fatalf("Cannot load policy, %s", err.Error())
+		}
+	}
+
+	c := Config.ServerConfig
+	if err := checkCertificates(c); err != nil {
+		log.Fatalf("Cannot start server, %s", err.Error())
+	}
+
+	if c.EnableHTTPS && c.RedirectAddr != "" {
+		go runRedirect(c)
+	}
+
+	log.Infof("Server running on %s://%s", scheme(c), c.HTTPAddr)
+	if c.Graceful {
+		runGraceful()
+		return
+	}
+
+	Echo.Run(newServer(c, nil))
 }
 
 func listRoutes() {
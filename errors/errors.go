@@ -0,0 +1,122 @@
+package errors
+
+import (
+	"fmt"
+
+	"github.com/qasico/cuxs/response"
+)
+
+// Error is an application error carrying the http status, a machine
+// readable code and a message safe to show to the client.
+// The cause is only shown when ShowCause is enabled.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Cause   error
+}
+
+// ShowCause includes the cause of errors in responses, set from APP_DEBUG.
+var ShowCause = false
+
+// New returns an application error.
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap returns an application error caused by cause.
+func Wrap(cause error, status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message, Cause: cause}
+}
+
+func BadRequest(code string, message string) *Error {
+	return New(response.StatusBadRequest, code, message)
+}
+
+func Unauthorized(code string, message string) *Error {
+	return New(response.StatusUnauthorized, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return New(response.StatusForbidden, code, message)
+}
+
+func NotFound(code string, message string) *Error {
+	return New(response.StatusNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return New(response.StatusConflict, code, message)
+}
+
+// Internal wraps an unexpected error, its message is never shown.
+func Internal(cause error) *Error {
+	return Wrap(cause, response.StatusInternalServerError, "internal_error", response.StatusText(response.StatusInternalServerError))
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.Cause.Error())
+	}
+
+	return e.Message
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithCause returns a copy of the error caused by cause,
+// to reuse the errors declared in a catalog.
+func (e *Error) WithCause(cause error) *Error {
+	c := *e
+	c.Cause = cause
+
+	return &c
+}
+
+// Is reports errors of the same catalog entry as equal.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+
+	return ok && t.Status == e.Status && t.Code == e.Code
+}
+
+// As returns the application error in the chain of err.
+func As(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+
+		err = u.Unwrap()
+	}
+
+	return nil, false
+}
+
+// Fill sets the failed response of err when it is an application error.
+func Fill(r *response.Attribute, err error) bool {
+	e, ok := As(err)
+	if !ok {
+		return false
+	}
+
+	r.Code = e.Status
+	r.Status = response.StatusFailed
+	r.Message = e.Message
+	r.ErrorCode = e.Code
+	r.Data = nil
+
+	if ShowCause && e.Cause != nil {
+		r.Cause = e.Cause.Error()
+	}
+
+	return true
+}
@@ -0,0 +1,220 @@
+package cuxs
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldTree is the sparse fieldset parsed from ?field=id,customer.name,items(sku,qty),
+// a nil subtree selects the whole value of the field.
+type fieldTree map[string]fieldTree
+
+type fieldParser struct {
+	s     string
+	i     int
+	items []string
+}
+
+// parseFields parses the field query string into a fieldTree
+// and the list of its top level items.
+func parseFields(s string) (fieldTree, []string, error) {
+	p := &fieldParser{s: s}
+	t, err := p.list(0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t, p.items, nil
+}
+
+func (p *fieldParser) list(depth int) (fieldTree, error) {
+	t := fieldTree{}
+	for {
+		start := p.i
+		if err := p.item(t); err != nil {
+			return nil, err
+		}
+
+		if depth == 0 {
+			p.items = append(p.items, p.s[start:p.i])
+		}
+
+		switch {
+		case p.i < len(p.s) && p.s[p.i] == ',':
+			p.i++
+		case depth > 0 && p.i < len(p.s) && p.s[p.i] == ')':
+			p.i++
+			return t, nil
+		case depth == 0 && p.i == len(p.s):
+			return t, nil
+		default:
+			return nil, &ruleError{"syntax", strconv.Itoa(p.i + 1)}
+		}
+	}
+}
+
+func (p *fieldParser) item(t fieldTree) (err error) {
+	start := p.i
+	for p.i < len(p.s) && isFieldChar(p.s[p.i]) {
+		p.i++
+	}
+
+	name := p.s[start:p.i]
+	if name == "" {
+		return &ruleError{"syntax", strconv.Itoa(p.i + 1)}
+	}
+
+	var sub fieldTree
+	if p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '.':
+			p.i++
+			sub = fieldTree{}
+			err = p.item(sub)
+		case '(':
+			p.i++
+			sub, err = p.list(1)
+		}
+	}
+
+	if err == nil {
+		t.merge(name, sub)
+	}
+
+	return
+}
+
+func (t fieldTree) merge(name string, sub fieldTree) {
+	current, ok := t[name]
+	switch {
+	case !ok:
+		t[name] = sub
+	case current == nil || sub == nil:
+		t[name] = nil
+	default:
+		for k, v := range sub {
+			current.merge(k, v)
+		}
+	}
+}
+
+func isFieldChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// selectFields returns the fields of v selected by the tree as maps,
+// walking through pointers, interfaces, slices, structs and maps.
+// Names listed in optional are skipped when missing instead of failing.
+func selectFields(v reflect.Value, tree fieldTree, path string, optional map[string]bool) (interface{}, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+
+		out := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			x, err := selectFields(v.Index(i), tree, path, optional)
+			if err != nil {
+				return nil, err
+			}
+
+			out[i] = x
+		}
+
+		return out, nil
+	case reflect.Struct, reflect.Map:
+		out := make(map[string]interface{}, len(tree))
+		for name, sub := range tree {
+			key, f, ok := lookupField(v, name)
+			if !ok {
+				if optional[name] {
+					continue
+				}
+
+				return nil, &ruleError{"unknown_field", path + name}
+			}
+
+			if sub == nil {
+				out[key] = f.Interface()
+				continue
+			}
+
+			x, err := selectFields(f, sub, path+name+".", nil)
+			if err != nil {
+				return nil, err
+			}
+
+			out[key] = x
+		}
+
+		return out, nil
+	}
+
+	return nil, &ruleError{"subfields", strings.TrimSuffix(path, ".")}
+}
+
+// lookupField returns the output key and value of the json named field
+// of a struct or string keyed map.
+func lookupField(v reflect.Value, name string) (string, reflect.Value, bool) {
+	if v.Kind() == reflect.Map {
+		if v.Type().Key().Kind() != reflect.String {
+			return "", reflect.Value{}, false
+		}
+
+		f := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+
+		return name, f, f.IsValid()
+	}
+
+	f, ok := structField(v, name)
+
+	return name, f, ok
+}
+
+// structField finds the field by its json name, including the
+// fields promoted from embedded structs.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" || (sf.PkgPath != "" && !sf.Anonymous) {
+			continue
+		}
+
+		if sf.Anonymous && strings.Split(tag, ",")[0] == "" {
+			e := v.Field(i)
+			if e.Kind() == reflect.Ptr {
+				if e.IsNil() {
+					continue
+				}
+
+				e = e.Elem()
+			}
+
+			if e.Kind() == reflect.Struct {
+				if f, ok := structField(e, name); ok {
+					return f, true
+				}
+
+				continue
+			}
+		}
+
+		if jsonName(tag, sf.Name) == name {
+			return v.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
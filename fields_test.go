@@ -0,0 +1,90 @@
+package cuxs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		in    string
+		tree  fieldTree
+		items []string
+		err   bool
+	}{
+		{"id", fieldTree{"id": nil}, []string{"id"}, false},
+		{"id,name", fieldTree{"id": nil, "name": nil}, []string{"id", "name"}, false},
+		{"customer.name", fieldTree{"customer": {"name": nil}}, []string{"customer.name"}, false},
+		{"items(sku,qty)", fieldTree{"items": {"sku": nil, "qty": nil}}, []string{"items(sku,qty)"}, false},
+		{"a(b.c,d(e))", fieldTree{"a": {"b": {"c": nil}, "d": {"e": nil}}}, []string{"a(b.c,d(e))"}, false},
+		{"customer.name,customer.email", fieldTree{"customer": {"name": nil, "email": nil}}, []string{"customer.name", "customer.email"}, false},
+		{"customer,customer.name", fieldTree{"customer": nil}, []string{"customer", "customer.name"}, false},
+		{"", nil, nil, true},
+		{"id,", nil, nil, true},
+		{"items(sku", nil, nil, true},
+		{"items)", nil, nil, true},
+		{"a.", nil, nil, true},
+		{"a b", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		tree, items, err := parseFields(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v", tt.in, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(tree, tt.tree) || !reflect.DeepEqual(items, tt.items) {
+			t.Errorf("%q: got %v %v, want %v %v", tt.in, tree, items, tt.tree, tt.items)
+		}
+	}
+}
+
+type fieldsCustomer struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type fieldsOrder struct {
+	Id       int64             `json:"id"`
+	Secret   string            `json:"-"`
+	Customer *fieldsCustomer   `json:"customer"`
+	Items    []fieldsCustomer  `json:"items"`
+	Extra    map[string]string `json:"extra"`
+}
+
+func TestSelectFields(t *testing.T) {
+	o := &fieldsOrder{Id: 1, Secret: "s", Customer: &fieldsCustomer{Name: "a", Email: "e"}, Items: []fieldsCustomer{{Name: "b"}}, Extra: map[string]string{"k": "v"}}
+
+	tests := []struct {
+		field string
+		want  interface{}
+		err   bool
+	}{
+		{"id", map[string]interface{}{"id": int64(1)}, false},
+		{"customer.name", map[string]interface{}{"customer": map[string]interface{}{"name": "a"}}, false},
+		{"items(name)", map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "b"}}}, false},
+		{"extra.k", map[string]interface{}{"extra": map[string]interface{}{"k": "v"}}, false},
+		{"secret", nil, true},
+		{"Secret", nil, true},
+		{"id.x", nil, true},
+		{"customer.phone", nil, true},
+	}
+
+	for _, tt := range tests {
+		tree, _, err := parseFields(tt.field)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := selectFields(reflect.ValueOf(o), tree, "", nil)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v", tt.field, err)
+			continue
+		}
+
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.field, got, tt.want)
+		}
+	}
+}
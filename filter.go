@@ -0,0 +1,144 @@
+package cuxs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Filter is a single condition parsed from ?filter[field][operator]=value.
+	Filter struct {
+		Field    string
+		Operator string
+		Value    []string
+	}
+
+	// FilterRules whitelists the filterable json fields of a resource and
+	// their allowed operators, no operators means every operator is allowed.
+	FilterRules map[string][]string
+)
+
+const (
+	FilterEq     = "eq"
+	FilterNe     = "ne"
+	FilterGt     = "gt"
+	FilterGte    = "gte"
+	FilterLt     = "lt"
+	FilterLte    = "lte"
+	FilterIn     = "in"
+	FilterLike   = "like"
+	FilterIsNull = "isnull"
+)
+
+var (
+	filterOperators = []string{FilterEq, FilterNe, FilterGt, FilterGte, FilterLt, FilterLte, FilterIn, FilterLike, FilterIsNull}
+	matchFilter     = regexp.MustCompile(`^filter\[([A-Za-z0-9_.]+)\](?:\[([a-z]+)\])?$`)
+)
+
+// parseFilters reads the filter and q query string, malformed
+// filters are added as validation errors on the response.
+func (h *Handler) parseFilters(qs map[string][]string) {
+	keys := make([]string, 0, len(qs))
+	for k := range qs {
+		if strings.HasPrefix(k, "filter[") {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		m := matchFilter.FindStringSubmatch(k)
+		if m == nil {
+			h.setParamError(k, "invalid", "")
+			continue
+		}
+
+		f := Filter{Field: m[1], Operator: m[2], Value: []string{qs[k][0]}}
+		if f.Operator == "" {
+			f.Operator = FilterEq
+		}
+
+		if !inStrings(filterOperators, f.Operator) {
+			h.setParamError(k, "operator", f.Operator)
+			continue
+		}
+
+		switch f.Operator {
+		case FilterIn:
+			f.Value = strings.Split(qs[k][0], ",")
+		case FilterIsNull:
+			if _, err := strconv.ParseBool(qs[k][0]); err != nil {
+				h.setParamError(k, "boolean", "")
+				continue
+			}
+		}
+
+		h.QueryParam.Filters = append(h.QueryParam.Filters, f)
+	}
+
+	if param, ok := qs["q"]; ok && param[0] != "" {
+		h.QueryParam.Search = param[0]
+	}
+}
+
+// AllowFilters checks the parsed filters against the resource whitelist,
+// rejected filters are added as validation errors on the response.
+func (h *Handler) AllowFilters(rules FilterRules) (valid bool) {
+	valid = true
+	if h.QueryParam == nil {
+		return
+	}
+
+	for _, f := range h.QueryParam.Filters {
+		name := fmt.Sprintf("filter[%s][%s]", f.Field, f.Operator)
+		ops, ok := rules[f.Field]
+		if !ok {
+			h.setParamError(name, "filterable", f.Field)
+			valid = false
+		} else if len(ops) > 0 && !inStrings(ops, f.Operator) {
+			h.setParamError(name, "operator", f.Operator)
+			valid = false
+		}
+	}
+
+	return
+}
+
+// AllowSearch sets the fields matched by the q search term, a search
+// on a resource without searchable fields is a validation error.
+func (h *Handler) AllowSearch(fields []string) (valid bool) {
+	valid = true
+	if h.QueryParam == nil || h.QueryParam.Search == "" {
+		return
+	}
+
+	if len(fields) == 0 {
+		h.setParamError("q", "searchable", "")
+		return false
+	}
+
+	h.QueryParam.search = fields
+
+	return
+}
+
+// IsNull returns the parsed value of an isnull filter.
+func (f Filter) IsNull() bool {
+	b, _ := strconv.ParseBool(f.Value[0])
+
+	return b
+}
+
+func inStrings(s []string, v string) bool {
+	for _, a := range s {
+		if a == v {
+			return true
+		}
+	}
+
+	return false
+}
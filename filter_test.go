@@ -0,0 +1,91 @@
+package cuxs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qasico/cuxs/response"
+)
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		qs      map[string][]string
+		filters []Filter
+		search  string
+		errors  int
+	}{
+		{map[string][]string{"filter[status]": {"active"}}, []Filter{{"status", FilterEq, []string{"active"}}}, "", 0},
+		{map[string][]string{"filter[total][gte]": {"100"}}, []Filter{{"total", FilterGte, []string{"100"}}}, "", 0},
+		{map[string][]string{"filter[id][in]": {"1,2"}}, []Filter{{"id", FilterIn, []string{"1", "2"}}}, "", 0},
+		{map[string][]string{"filter[deleted_at][isnull]": {"true"}}, []Filter{{"deleted_at", FilterIsNull, []string{"true"}}}, "", 0},
+		{map[string][]string{"filter[deleted_at][isnull]": {"maybe"}}, nil, "", 1},
+		{map[string][]string{"filter[total][between]": {"1"}}, nil, "", 1},
+		{map[string][]string{"filter[a;b]": {"1"}}, nil, "", 1},
+		{map[string][]string{"q": {"term"}}, nil, "term", 0},
+		{map[string][]string{"filter[b]": {"2"}, "filter[a][ne]": {"1"}}, []Filter{{"a", FilterNe, []string{"1"}}, {"b", FilterEq, []string{"2"}}}, "", 0},
+	}
+
+	for _, tt := range tests {
+		h := &Handler{QueryParam: new(QueryParam), Response: new(response.Attribute)}
+		h.parseFilters(tt.qs)
+
+		if !reflect.DeepEqual(h.QueryParam.Filters, tt.filters) || h.QueryParam.Search != tt.search || len(h.Response.Errors) != tt.errors {
+			t.Errorf("%v: got %v %q %v", tt.qs, h.QueryParam.Filters, h.QueryParam.Search, h.Response.Errors)
+		}
+	}
+}
+
+func TestAllowFilters(t *testing.T) {
+	tests := []struct {
+		filter Filter
+		rules  FilterRules
+		valid  bool
+	}{
+		{Filter{"status", FilterEq, []string{"a"}}, FilterRules{"status": nil}, true},
+		{Filter{"status", FilterLike, []string{"a"}}, FilterRules{"status": {FilterEq, FilterIn}}, false},
+		{Filter{"secret", FilterEq, []string{"a"}}, FilterRules{"status": nil}, false},
+		{Filter{"status", FilterEq, []string{"a"}}, nil, false},
+	}
+
+	for _, tt := range tests {
+		h := &Handler{QueryParam: &QueryParam{Filters: []Filter{tt.filter}}, Response: new(response.Attribute)}
+		if valid := h.AllowFilters(tt.rules); valid != tt.valid || len(h.Response.Errors) > 0 == valid {
+			t.Errorf("%v %v: got %v %v, want %v", tt.filter, tt.rules, valid, h.Response.Errors, tt.valid)
+		}
+	}
+}
+
+func TestResourceSearch(t *testing.T) {
+	e, db := newTestResource(t, ResourceOptions{Search: []string{"name", "email"}})
+	db.Create(&resourceModel{Id: 2, Name: "bob", Email: "b@y.com"})
+	db.Create(&resourceModel{Id: 3, Name: "carol", Email: "c@x.com"})
+
+	tests := []struct {
+		q    string
+		code int
+		ids  []float64
+	}{
+		{"bo", 200, []float64{2}},
+		{"x.com", 200, []float64{1, 3}},
+		{"zzz", 200, nil},
+	}
+
+	for _, tt := range tests {
+		code, res := serveTest(e, "GET", "/models?q="+tt.q, "")
+		var ids []float64
+		items, _ := res["data"].([]interface{})
+		for _, it := range items {
+			ids = append(ids, it.(map[string]interface{})["id"].(float64))
+		}
+
+		if code != tt.code || !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("q=%s: got %d %v, want %d %v", tt.q, code, ids, tt.code, tt.ids)
+		}
+	}
+
+	// nothing is searchable without fields
+	e, _ = newTestResource(t, ResourceOptions{})
+	if code, _ := serveTest(e, "GET", "/models?q=a", ""); code != 422 {
+		t.Errorf("unsearchable resource: got %d, want 422", code)
+	}
+}
@@ -1,17 +1,20 @@
 package cuxs
 
 import (
-	"errors"
-	"fmt"
+	"encoding/json"
 	"log"
 	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/fatih/structs"
+	"github.com/jinzhu/gorm"
 	"github.com/labstack/echo"
+	cerrors "github.com/qasico/cuxs/errors"
 	"github.com/qasico/cuxs/helper"
+	"github.com/qasico/cuxs/idcodec"
 	"github.com/qasico/cuxs/response"
 	"gopkg.in/go-playground/validator.v8"
 )
@@ -29,32 +32,86 @@ type (
 		ResponseHandler *ResponseHandler
 		RequestInput    []string
 		QueryParam      *QueryParam
+
+		// Strict rejects json body fields unknown to the request.
+		Strict bool
+
+		// Partial validates only the request fields present in the body,
+		// for partial updates.
+		Partial bool
+
+		// DB is the database of the unique and exist rules, default to
+		// DBOf the request, and RowID the primary key of the changed row
+		// that unique excludes, default to the Id field of the request.
+		DB    *gorm.DB
+		RowID interface{}
+
+		body []byte
 	}
 
 	QueryParam struct {
-		Count  bool
-		Sort   string
-		Offset int
-		Limit  int
-		Id     []string
-		Field  []string
-		Embed  []string
+		Count   bool
+		Sort    string
+		Sorts   []SortField
+		Offset  int
+		Limit   int
+		Page    int
+		Id      []string
+		Field   []string
+		Embed   []string
+		Filters []Filter
+		Search  string
+		Total   int64
+
+		CursorMode bool
+		Cursor     *Cursor
+		NextCursor string
+		PrevCursor string
+
+		fields fieldTree
+		search []string
+		keyset []SortField
+		schema *modelSchema
 	}
 )
 
-var ApiHandler *Handler
+// HandlerKey is the echo.Context key where the request scoped handler is stored.
+const HandlerKey = "cuxs.handler"
+
+var (
+	// ApiHandler holds the last prepared handler.
+	//
+	// Deprecated: it is shared between concurrent requests,
+	// use HandlerOf with the request context instead.
+	ApiHandler *Handler
+
+	apiHandlerMu sync.Mutex
+)
+
+// NewHandler returns a fresh handler prepared for the request context.
+func NewHandler(c echo.Context, req RequestHandler) (*Handler, error) {
+	return new(Handler).Prepare(c, req)
+}
+
+// HandlerOf returns the handler prepared for the request context,
+// or nil when no handler has been prepared yet.
+func HandlerOf(c echo.Context) *Handler {
+	if h, ok := c.Get(HandlerKey).(*Handler); ok {
+		return h
+	}
+
+	return nil
+}
 
 func (h *Handler) Prepare(c echo.Context, req RequestHandler) (hr *Handler, err error) {
-	h.Validate = validator.New(&validator.Config{TagName: "validate"})
-	h.Validate.RegisterValidation("encrypted", Validencrypted)
+	h.Validate = Validator()
 
 	h.Response = &response.Attribute{Code: response.StatusBadRequest, Status: response.StatusFailed, Message: response.StatusText(response.StatusBadRequest)}
 	h.Context = c
 	h.ResponseHandler = nil
 
 	if req != nil {
-		h.Context.Bind(&req)
-		if err = h.validateRequest(req); err == nil {
+		if err = h.bindRequest(req); err == nil {
 			h.RequestHandler = &req
 		}
 	}
@@ -63,11 +120,16 @@ func (h *Handler) Prepare(c echo.Context, req RequestHandler) (hr *Handler, err
 		h.setQueryParam(c)
 	}
 
+	c.Set(HandlerKey, h)
+
+	apiHandlerMu.Lock()
 	ApiHandler = h
+	apiHandlerMu.Unlock()
 
 	return h, err
 }
 
+// Deprecated: use the idcodec validator with an idcodec tagged field.
 func Validencrypted(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
 
 	i, err := strconv.Atoi(field.String())
@@ -83,8 +145,52 @@ func Validencrypted(v *validator.Validate, topStruct reflect.Value, currentStruc
 	return true
 }
 
+// ValidIdcodec checks that the string field can be decoded by the id codec.
+func ValidIdcodec(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+	if field.Kind() != reflect.String {
+		return false
+	}
+
+	_, err := idcodec.Decode(field.String())
+
+	return err == nil
+}
+
+// bindRequest binds the request, decodes its opaque ids and validates it.
+func (h *Handler) bindRequest(req interface{}) error {
+	if err := h.bind(req); err != nil {
+		return err
+	}
+
+	if err := idcodec.DecodeFields(req); err != nil {
+		if fe, ok := err.(*idcodec.FieldError); ok {
+			h.setRuleError(fe.Field, "idcodec", "", reflect.String)
+		}
+
+		return err
+	}
+
+	return h.validateRequest(req)
+}
+
 func (h *Handler) validateRequest(req interface{}) (err error) {
-	if err = h.Validate.Struct(req); err != nil {
+	if h.Partial {
+		h.requestKeys(req)
+		if len(h.RequestInput) == 0 {
+			return nil
+		}
+
+		fields := make([]string, len(h.RequestInput))
+		for i, k := range h.RequestInput {
+			fields[i] = helper.CamelCase(k)
+		}
+
+		err = h.validate(req, fields...)
+	} else {
+		err = h.validate(req)
+	}
+
+	if err != nil {
 		h.ValidationError(err.(validator.ValidationErrors))
 	} else {
 		h.requestKeys(req)
@@ -93,10 +199,77 @@ func (h *Handler) validateRequest(req interface{}) (err error) {
 	return
 }
 
+// validate runs the rules of req, or of the given fields only, with the
+// handler as the scope of the database rules.
+func (h *Handler) validate(req interface{}, fields ...string) error {
+	if v := reflect.ValueOf(req); v.Kind() == reflect.Ptr && !v.IsNil() {
+		validating.Store(v.Pointer(), h)
+		defer validating.Delete(v.Pointer())
+	}
+
+	if len(fields) > 0 {
+		return h.Validate.StructPartial(req, fields...)
+	}
+
+	return h.Validate.Struct(req)
+}
+
 func (r *Handler) ValidationError(errs validator.ValidationErrors) {
 	for _, e := range errs {
-		x := response.ErrorValidation{Field: helper.SnakeCase(e.Field), Message: e.Tag}
-		r.Response.Errors = append(r.Response.Errors, x)
+		r.setRuleError(e.Field, e.Tag, e.Param, e.Kind)
+	}
+}
+
+// setRuleError adds the validation error of the rule translated in the request locale.
+func (h *Handler) setRuleError(field string, rule string, param string, kind reflect.Kind) {
+	x := response.ErrorValidation{Field: helper.SnakeCase(field), Message: Translate(h.Locale(), rule, param, kind), Rule: rule, Param: param}
+
+	h.Response.Errors = append(h.Response.Errors, x)
+}
+
+// paramErrors adds the ParamError held by err, alone or among the
+// errors of a gorm.DB, as validation errors of the query param.
+func (h *Handler) paramErrors(err error) (found bool) {
+	errs := []error{err}
+	if e, ok := err.(gorm.Errors); ok {
+		errs = e.GetErrors()
+	}
+
+	for _, e := range errs {
+		if pe, ok := e.(*ParamError); ok {
+			h.setParamError(pe.Param, "unknown_field", pe.Field)
+			found = true
+		}
+	}
+
+	return
+}
+
+// setParamError adds the validation error of the rule translated in the
+// request locale on a request param, the field is kept as it is named.
+func (h *Handler) setParamError(field string, rule string, param string) {
+	x := response.ErrorValidation{Field: field, Message: Translate(h.Locale(), rule, param, reflect.Invalid), Rule: rule, Param: param}
+
+	h.Response.Errors = append(h.Response.Errors, x)
+}
+
+// ruleError is an invalid request param, rule is the catalog key of its message.
+type ruleError struct {
+	rule  string
+	param string
+}
+
+func (e *ruleError) Error() string {
+	return Translate("en", e.rule, e.param, reflect.Invalid)
+}
+
+// setParamErrorOf adds err as validation error of the request param,
+// translated when it is a ruleError.
+func (h *Handler) setParamErrorOf(field string, err error) {
+	if e, ok := err.(*ruleError); ok {
+		h.setParamError(field, e.rule, e.param)
+	} else {
+		h.setParamError(field, "invalid", "")
 	}
 }
 
@@ -104,7 +277,7 @@ func (h *Handler) requestKeys(i interface{}) {
 	var objmap map[string]interface{}
 
 	rm := structs.Map(i)
-	h.Context.Bind(&objmap)
+	json.Unmarshal(h.body, &objmap)
 	keys := make([]string, 0, len(objmap))
 	for k := range objmap {
 		kk := helper.CamelCase(k)
@@ -119,11 +292,16 @@ func (h *Handler) requestKeys(i interface{}) {
 func (h *Handler) setQueryParam(c echo.Context) {
 	qp := new(QueryParam)
 	qs := c.QueryParams()
-	qp.Limit = 10
+	size := pageSizeOf(c)
+	qp.Limit = size.Default
 	qp.Offset = 0
+	qp.Page = 1
 
 	if param, ok := qs["count"]; ok && param[0] != "" {
-		qp.Count, _ = strconv.ParseBool(param[0])
+		var err error
+		if qp.Count, err = strconv.ParseBool(param[0]); err != nil {
+			h.setParamError("count", "boolean", "")
+		}
 	}
 
 	if param, ok := qs["embed"]; ok && param[0] != "" {
@@ -131,7 +309,10 @@ func (h *Handler) setQueryParam(c echo.Context) {
 	}
 
 	if param, ok := qs["field"]; ok && param[0] != "" {
-		qp.Field = strings.Split(param[0], ",")
+		var err error
+		if qp.fields, qp.Field, err = parseFields(param[0]); err != nil {
+			h.setParamErrorOf("field", err)
+		}
 	}
 
 	if param, ok := qs["id"]; ok && param[0] != "" {
@@ -139,44 +320,48 @@ func (h *Handler) setQueryParam(c echo.Context) {
 	}
 
 	if param, ok := qs["per_page"]; ok && param[0] != "" {
-		qp.Limit, _ = strconv.Atoi(param[0])
+		if limit, err := strconv.Atoi(param[0]); err != nil || limit < 1 || limit > size.Max {
+			h.setParamError("per_page", "page_size", strconv.Itoa(size.Max))
+		} else {
+			qp.Limit = limit
+		}
 	}
 
 	if param, ok := qs["page"]; ok && param[0] != "" {
-		limit := 10
-		page, _ := strconv.Atoi(param[0])
-
-		if qp.Limit != 0 {
-			limit = qp.Limit
+		if page, err := strconv.Atoi(param[0]); err != nil || page < 1 {
+			h.setParamError("page", "page", "")
+		} else {
+			qp.Page = page
+			qp.Offset = (page - 1) * qp.Limit
 		}
-
-		qp.Offset = (page - 1) * limit
 	}
 
-	if param, ok := qs["sort"]; ok && param[0] != "" {
-		sort := param[0]
-		order := "asc"
-		if string(sort[0]) == "-" {
-			sort = strings.Replace(sort, "-", "", -1)
-			order = "desc"
-		}
+	h.QueryParam = qp
+	h.parseFilters(qs)
 
-		qp.Sort = fmt.Sprintf("%s %s", sort, order)
+	if param, ok := qs["sort"]; ok && param[0] != "" {
+		h.parseSort(param[0])
 	}
 
-	h.QueryParam = qp
+	h.parseCursor(qs)
 }
 
 func (h *Handler) GetResponse(err error) (int, interface{}) {
+	if err != nil {
+		h.paramErrors(err)
+	}
+
 	// check if errors has contain data
 	if len(h.Response.Errors) > 0 {
 		h.Response.SetCode(response.StatusUnprocessableEntry)
 		h.Response.SetMessage(response.StatusText(response.StatusUnprocessableEntry))
 	} else {
 		if err != nil {
-			h.Response.SetCode(response.StatusBadRequest)
-			h.Response.SetMessage(err.Error())
-			h.Response.Data = nil
+			// untyped errors may hold internals, their text is only
+			// shown as the cause when EnableErrorsShow is on
+			if !cerrors.Fill(h.Response, err) {
+				cerrors.Fill(h.Response, cerrors.Wrap(err, response.StatusBadRequest, "bad_request", response.StatusText(response.StatusBadRequest)))
+			}
 		} else {
 			if h.Response.Code > 300 {
 				h.Response.SetCode(http.StatusOK)
@@ -189,7 +374,32 @@ func (h *Handler) GetResponse(err error) (int, interface{}) {
 				h.Response.Data = h.ResponseHandler
 			}
 
+			if h.QueryParam != nil && h.QueryParam.Count && h.Response.Total == 0 {
+				h.Response.Total = h.QueryParam.Total
+			}
+
+			if h.QueryParam != nil && h.QueryParam.CursorMode {
+				h.Response.NextCursor = h.QueryParam.NextCursor
+				h.Response.PrevCursor = h.QueryParam.PrevCursor
+			}
+
+			if d, err := idcodec.Encoded(h.Response.Data); err != nil {
+				log.Print(err)
+			} else {
+				h.Response.Data = d
+			}
+
 			h.FilterResponse()
+
+			// field selection can reject unknown fields
+			if len(h.Response.Errors) > 0 {
+				h.Response.SetCode(response.StatusUnprocessableEntry)
+				h.Response.SetMessage(response.StatusText(response.StatusUnprocessableEntry))
+				h.Response.Status = response.StatusFailed
+				h.Response.Data = nil
+			} else {
+				h.setPagination()
+			}
 		}
 	}
 
@@ -200,43 +410,40 @@ func (h *Handler) GetResponse(err error) (int, interface{}) {
 	return h.Response.Code, h.Response
 }
 
+// Respond writes the response of GetResponse in the media type
+// negotiated from the Accept header.
+func (h *Handler) Respond(err error) error {
+	code, res := h.GetResponse(err)
+
+	return response.Render(h.Context, code, res)
+}
+
+// FilterResponse reduces the response data to the fields selected by the
+// field query string, embeds are kept unless a sub selection is given.
+// Unknown fields are added as validation errors on the response.
 func (h *Handler) FilterResponse() {
 	// run only GET requests
-	if h.Context.Request().Method() == "GET" && len(h.QueryParam.Field) > 0 && h.Response.Data != nil && h.Response.Status == response.StatusSuccess {
-		// filter the Response.Data
-		d := h.Response.Data
-		switch reflect.TypeOf(d).Kind() {
-		case reflect.Slice:
-			s := reflect.ValueOf(d)
-			var result []interface{}
-			for i := 0; i < s.Len(); i++ {
-				m := make(map[string]interface{})
-				x := s.Index(i)
-				for _, fname := range h.QueryParam.Field {
-					if fname == "id" {
-						fname = "id_e"
-					}
-
-					m[fname] = x.FieldByName(helper.CamelCase(fname)).Interface()
-				}
-				result = append(result, m)
-			}
-			h.Response.Data = result
-		case reflect.Ptr:
-			rm := structs.Map(d)
-			x := make(map[string]interface{})
-			for _, fname := range h.QueryParam.Field {
-				if fname == "id" {
-					fname = "id_e"
-				}
-				kk := helper.CamelCase(fname)
-				if _, ok := rm[kk]; ok {
-					x[fname] = rm[kk]
-				}
+	if h.Context.Request().Method() == "GET" && h.QueryParam.fields != nil && h.Response.Data != nil && h.Response.Status == response.StatusSuccess {
+		tree := fieldTree{}
+		for k, v := range h.QueryParam.fields {
+			tree[k] = v
+		}
+
+		optional := map[string]bool{}
+		for _, e := range h.QueryParam.Embed {
+			if _, ok := tree[e]; !ok {
+				tree[e] = nil
+				optional[e] = true
 			}
+		}
 
-			h.Response.Data = x
+		d, err := selectFields(reflect.ValueOf(h.Response.Data), tree, "", optional)
+		if err != nil {
+			h.setParamErrorOf("field", err)
+			return
 		}
+
+		h.Response.Data = d
 	}
 }
 
@@ -249,11 +456,10 @@ func (h *Handler) Valid(name string, field interface{}, rule string) error {
 	if err := h.Validate.Field(field, rule); err != nil {
 		errs := err.(validator.ValidationErrors)
 		for _, e := range errs {
-			x := response.ErrorValidation{Field: helper.SnakeCase(name), Message: e.Tag}
-			h.Response.Errors = append(h.Response.Errors, x)
+			h.setRuleError(name, e.Tag, e.Param, e.Kind)
 		}
 
-		return errors.New("Validation Failed")
+		return errValidation
 	}
 
 	return nil
@@ -271,17 +477,66 @@ func (q *QueryParam) IsEmbed(field string) bool {
 	return false
 }
 
+func (h *Handler) SetResponseMessage(err string) {
+	h.Response.SetCode(response.StatusBadRequest)
+	h.Response.Message = err
+}
+
+func (h *Handler) SetHandler(res ResponseHandler) {
+	h.ResponseHandler = &res
+}
+
+func (h *Handler) SetErrorValidate(field string, message string) {
+	x := response.ErrorValidation{Field: helper.SnakeCase(field), Message: message}
+
+	h.Response.Errors = append(h.Response.Errors, x)
+}
+
+// SetResponseMessageContext sets the failure message on the handler of the request context.
+func SetResponseMessageContext(c echo.Context, err string) {
+	if h := HandlerOf(c); h != nil {
+		h.SetResponseMessage(err)
+	}
+}
+
+// SetHandlerContext sets the response data on the handler of the request context.
+func SetHandlerContext(c echo.Context, res ResponseHandler) {
+	if h := HandlerOf(c); h != nil {
+		h.SetHandler(res)
+	}
+}
+
+// SetErrorValidateContext adds a validation error on the handler of the request context.
+func SetErrorValidateContext(c echo.Context, field string, message string) {
+	if h := HandlerOf(c); h != nil {
+		h.SetErrorValidate(field, message)
+	}
+}
+
+// Deprecated: use SetResponseMessageContext or Handler.SetResponseMessage.
 func SetResponseMessage(err string) {
-	ApiHandler.Response.SetCode(response.StatusBadRequest)
-	ApiHandler.Response.Message = err
+	if h := lastHandler(); h != nil {
+		h.SetResponseMessage(err)
+	}
 }
 
+// Deprecated: use SetHandlerContext or Handler.SetHandler.
 func SetHandler(res ResponseHandler) {
-	ApiHandler.ResponseHandler = &res
+	if h := lastHandler(); h != nil {
+		h.SetHandler(res)
+	}
 }
 
+// Deprecated: use SetErrorValidateContext or Handler.SetErrorValidate.
 func SetErrorValidate(field string, message string) {
-	x := response.ErrorValidation{Field: helper.SnakeCase(field), Message: message}
+	if h := lastHandler(); h != nil {
+		h.SetErrorValidate(field, message)
+	}
+}
+
+func lastHandler() *Handler {
+	apiHandlerMu.Lock()
+	defer apiHandlerMu.Unlock()
 
-	ApiHandler.Response.Errors = append(ApiHandler.Response.Errors, x)
+	return ApiHandler
 }
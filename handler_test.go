@@ -0,0 +1,211 @@
+package cuxs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo"
+	cerrors "github.com/qasico/cuxs/errors"
+	"github.com/qasico/cuxs/response"
+)
+
+type handlerRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// TestHandlerParallel prepares handlers of parallel requests, run it with
+// -race, every response must hold the data of its own request.
+func TestHandlerParallel(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("n%d", i)
+			method, target, body := "POST", "/items", fmt.Sprintf(`{"name":%q}`, name)
+			if i%2 == 1 {
+				method, target, body = "GET", fmt.Sprintf("/items?page=%d&per_page=5&count=true", i+1), ""
+			}
+
+			c, _ := newTestContext(method, target, "application/json", body)
+
+			req := new(handlerRequest)
+			if method == "GET" {
+				req = nil
+			}
+
+			var h *Handler
+			var err error
+			if req != nil {
+				h, err = NewHandler(c, req)
+			} else {
+				h, err = NewHandler(c, nil)
+			}
+
+			if err != nil {
+				t.Errorf("%s %s: %v", method, target, err)
+				return
+			}
+
+			if HandlerOf(c) != h {
+				t.Errorf("%s %s: context holds another handler", method, target)
+			}
+
+			if req != nil && req.Name != name {
+				t.Errorf("%s %s: bound %q, want %q", method, target, req.Name, name)
+			}
+
+			if method == "GET" && h.QueryParam.Page != i+1 {
+				t.Errorf("%s %s: page %d, want %d", method, target, h.QueryParam.Page, i+1)
+			}
+
+			SetHandlerContext(c, name)
+			lastHandler()
+
+			code, res := h.GetResponse(nil)
+			if code != 200 {
+				t.Errorf("%s %s: code %d", method, target, code)
+			}
+
+			if d, ok := h.Response.Data.(*ResponseHandler); !ok || *d != name || res != h.Response {
+				t.Errorf("%s %s: data %v, want %s", method, target, h.Response.Data, name)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestHandlerValidation(t *testing.T) {
+	c, _ := newTestContext("POST", "/items", "application/json", `{}`)
+
+	h, err := NewHandler(c, new(handlerRequest))
+	if err == nil {
+		t.Fatal("missing name is valid")
+	}
+
+	code, _ := h.GetResponse(err)
+	if code != 422 || len(h.Response.Errors) != 1 || h.Response.Errors[0].Field != "name" {
+		t.Fatalf("got %d %+v", code, h.Response.Errors)
+	}
+}
+
+func TestQueryParamErrors(t *testing.T) {
+	tests := []struct {
+		target, locale string
+		field, rule    string
+		message        string
+	}{
+		{"/items?count=maybe", "en", "count", "boolean", "must be true or false"},
+		{"/items?count=maybe", "id", "count", "boolean", "harus bernilai true atau false"},
+		{"/items?page=0", "id", "page", "page", "harus berupa angka lebih besar dari 0"},
+		{"/items?per_page=0", "en", "per_page", "page_size", fmt.Sprintf("must be a number between 1 and %d", Config.MaxPerPage)},
+		{"/items?field=a,", "id", "field", "syntax", "salah penulisan pada posisi 3"},
+		{"/items?filter[a][between]=1", "en", "filter[a][between]", "operator", "operator between is not allowed"},
+		{"/items?sort=a:b", "id", "sort", "invalid", "tidak valid"},
+		{"/items?cursor=x", "en", "cursor", "invalid", "is invalid"},
+	}
+
+	for _, tt := range tests {
+		c, _ := newTestContext("GET", tt.target, "", "")
+		c.Request().Header().Set("Accept-Language", tt.locale)
+
+		h, _ := NewHandler(c, nil)
+		if len(h.Response.Errors) != 1 {
+			t.Errorf("%s: got %+v, want one error", tt.target, h.Response.Errors)
+			continue
+		}
+
+		if e := h.Response.Errors[0]; e.Field != tt.field || e.Rule != tt.rule || e.Message != tt.message {
+			t.Errorf("%s %s: got %+v, want %s %s %q", tt.target, tt.locale, e, tt.field, tt.rule, tt.message)
+		}
+	}
+}
+
+func TestQueryParamPageSize(t *testing.T) {
+	tests := []struct {
+		target   string
+		size     *PageSize
+		limit    int
+		offset   int
+		count    bool
+		invalid  string
+		maxParam string
+	}{
+		{"/items", nil, Config.DefaultPerPage, 0, false, "", ""},
+		{"/items?per_page=5&page=3&count=true", nil, 5, 10, true, "", ""},
+		{fmt.Sprintf("/items?per_page=%d", Config.MaxPerPage), nil, Config.MaxPerPage, 0, false, "", ""},
+		{fmt.Sprintf("/items?per_page=%d", Config.MaxPerPage+1), nil, Config.DefaultPerPage, 0, false, "per_page", strconv.Itoa(Config.MaxPerPage)},
+		{"/items?per_page=0", nil, Config.DefaultPerPage, 0, false, "per_page", strconv.Itoa(Config.MaxPerPage)},
+		{"/items?per_page=x", nil, Config.DefaultPerPage, 0, false, "per_page", strconv.Itoa(Config.MaxPerPage)},
+		{"/items?page=0", nil, Config.DefaultPerPage, 0, false, "page", ""},
+		{"/items?page=x", nil, Config.DefaultPerPage, 0, false, "page", ""},
+		{"/items?count=x", nil, Config.DefaultPerPage, 0, false, "count", ""},
+		{"/items", &PageSize{Default: 3, Max: 6}, 3, 0, false, "", ""},
+		{"/items?per_page=6&page=2", &PageSize{Default: 3, Max: 6}, 6, 6, false, "", ""},
+		{"/items?per_page=7", &PageSize{Default: 3, Max: 6}, 3, 0, false, "per_page", "6"},
+	}
+
+	for _, tt := range tests {
+		c, rec := newTestContext("GET", tt.target, "", "")
+
+		var h *Handler
+		n := func(c echo.Context) error {
+			h, _ = NewHandler(c, nil)
+			return h.Respond(nil)
+		}
+
+		if tt.size != nil {
+			WithPageSize(tt.size.Default, tt.size.Max)(n)(c)
+		} else {
+			n(c)
+		}
+
+		q := h.QueryParam
+		if q.Limit != tt.limit || q.Offset != tt.offset || q.Count != tt.count {
+			t.Errorf("%s: got limit %d offset %d count %v", tt.target, q.Limit, q.Offset, q.Count)
+		}
+
+		if tt.invalid == "" {
+			if rec.Code != 200 {
+				t.Errorf("%s: got %d %+v, want 200", tt.target, rec.Code, h.Response.Errors)
+			}
+			continue
+		}
+
+		if rec.Code != 422 || len(h.Response.Errors) != 1 || h.Response.Errors[0].Field != tt.invalid || h.Response.Errors[0].Param != tt.maxParam {
+			t.Errorf("%s: got %d %+v, want 422 on %s", tt.target, rec.Code, h.Response.Errors, tt.invalid)
+		}
+	}
+}
+
+func TestGetResponseUntypedError(t *testing.T) {
+	defer func(v bool) { cerrors.ShowCause = v }(cerrors.ShowCause)
+
+	tests := []struct {
+		show  bool
+		cause string
+	}{
+		{false, ""},
+		{true, "pq: relation \"users\" does not exist"},
+	}
+
+	for _, tt := range tests {
+		cerrors.ShowCause = tt.show
+
+		c, _ := newTestContext("GET", "/items", "", "")
+		h, err := NewHandler(c, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := h.GetResponse(errors.New("pq: relation \"users\" does not exist"))
+		if code != response.StatusBadRequest || h.Response.Message != "Bad Request" || h.Response.Cause != tt.cause {
+			t.Errorf("show %v: got %d %v cause %q, want 400 Bad Request cause %q", tt.show, code, h.Response.Message, h.Response.Cause, tt.cause)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package idcodec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+)
+
+// Feistel permutes ids with a keyed Feistel network
+// and encodes the result in base62.
+type Feistel struct {
+	key    []byte
+	rounds int
+}
+
+const base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// NewFeistel returns a Feistel codec keyed with secret.
+func NewFeistel(secret string) *Feistel {
+	return &Feistel{key: []byte(secret), rounds: 4}
+}
+
+func (f *Feistel) Encode(id int64) (string, error) {
+	if id < 0 {
+		return "", ErrInvalidID
+	}
+
+	l, r := uint32(uint64(id)>>32), uint32(id)
+	for i := 0; i < f.rounds; i++ {
+		l, r = r, l^f.round(i, r)
+	}
+
+	return toBase62(uint64(l)<<32 | uint64(r)), nil
+}
+
+func (f *Feistel) Decode(s string) (int64, error) {
+	n, ok := fromBase62(s)
+	if !ok {
+		return 0, ErrInvalidID
+	}
+
+	l, r := uint32(n>>32), uint32(n)
+	for i := f.rounds - 1; i >= 0; i-- {
+		l, r = r^f.round(i, l), l
+	}
+
+	id := uint64(l)<<32 | uint64(r)
+	if id > 1<<63-1 {
+		return 0, ErrInvalidID
+	}
+
+	return int64(id), nil
+}
+
+func (f *Feistel) round(i int, v uint32) uint32 {
+	b := make([]byte, 5)
+	b[0] = byte(i)
+	binary.BigEndian.PutUint32(b[1:], v)
+
+	m := hmac.New(sha256.New, f.key)
+	m.Write(b)
+
+	return binary.BigEndian.Uint32(m.Sum(nil))
+}
+
+func toBase62(n uint64) string {
+	var out []byte
+	for {
+		out = append([]byte{base62[n%62]}, out...)
+		n /= 62
+		if n == 0 {
+			return string(out)
+		}
+	}
+}
+
+func fromBase62(s string) (uint64, bool) {
+	if s == "" || len(s) > 11 {
+		return 0, false
+	}
+
+	var n uint64
+	for _, c := range []byte(s) {
+		p := strings.IndexByte(base62, c)
+		if p < 0 || n > (1<<64-1-uint64(p))/62 {
+			return 0, false
+		}
+
+		n = n*62 + uint64(p)
+	}
+
+	// only the canonical encoding is accepted
+	if toBase62(n) != s {
+		return 0, false
+	}
+
+	return n, true
+}
@@ -0,0 +1,110 @@
+package idcodec
+
+// Hashids encodes ids with an alphabet shuffled by a salt,
+// following the hashids algorithm for a single number.
+type Hashids struct {
+	salt     []byte
+	alphabet []byte
+}
+
+const defaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+
+// NewHashids returns a Hashids codec salted with secret.
+func NewHashids(secret string) *Hashids {
+	h := &Hashids{salt: []byte(secret), alphabet: []byte(defaultAlphabet)}
+	shuffle(h.alphabet, h.salt)
+
+	return h
+}
+
+func (h *Hashids) Encode(id int64) (string, error) {
+	if id < 0 {
+		return "", ErrInvalidID
+	}
+
+	alphabet := make([]byte, len(h.alphabet))
+	copy(alphabet, h.alphabet)
+
+	lottery := alphabet[(id%100)%int64(len(alphabet))]
+	shuffle(alphabet, h.buffer(lottery, alphabet))
+
+	return string(lottery) + string(hash(id, alphabet)), nil
+}
+
+func (h *Hashids) Decode(s string) (int64, error) {
+	if len(s) < 2 {
+		return 0, ErrInvalidID
+	}
+
+	alphabet := make([]byte, len(h.alphabet))
+	copy(alphabet, h.alphabet)
+	shuffle(alphabet, h.buffer(s[0], alphabet))
+
+	id, ok := unhash([]byte(s[1:]), alphabet)
+	if !ok {
+		return 0, ErrInvalidID
+	}
+
+	// only the canonical encoding is accepted
+	if e, err := h.Encode(id); err != nil || e != s {
+		return 0, ErrInvalidID
+	}
+
+	return id, nil
+}
+
+func (h *Hashids) buffer(lottery byte, alphabet []byte) []byte {
+	b := append([]byte{lottery}, h.salt...)
+	b = append(b, alphabet...)
+
+	return b[:len(alphabet)]
+}
+
+// shuffle is the consistent shuffle of hashids.
+func shuffle(alphabet []byte, salt []byte) {
+	if len(salt) == 0 {
+		return
+	}
+
+	for i, v, p := len(alphabet)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+		v++
+	}
+}
+
+func hash(n int64, alphabet []byte) []byte {
+	l := int64(len(alphabet))
+	var out []byte
+	for {
+		out = append([]byte{alphabet[n%l]}, out...)
+		n /= l
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+func unhash(s []byte, alphabet []byte) (int64, bool) {
+	var n int64
+	for _, c := range s {
+		p := -1
+		for i, a := range alphabet {
+			if a == c {
+				p = i
+				break
+			}
+		}
+
+		if p < 0 || n > (1<<63-1-int64(p))/int64(len(alphabet)) {
+			return 0, false
+		}
+
+		n = n*int64(len(alphabet)) + int64(p)
+	}
+
+	return n, true
+}
@@ -0,0 +1,58 @@
+package idcodec
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Codec turns numeric ids into opaque strings and back.
+type Codec interface {
+	Encode(id int64) (string, error)
+	Decode(s string) (int64, error)
+}
+
+var (
+	ErrInvalidID = errors.New("invalid id")
+
+	current Codec = NewHashids("")
+	mutex   sync.RWMutex
+)
+
+// New returns the codec named hashids or feistel keyed with secret.
+func New(name string, secret string) (Codec, error) {
+	switch name {
+	case "", "hashids":
+		return NewHashids(secret), nil
+	case "feistel":
+		return NewFeistel(secret), nil
+	}
+
+	return nil, fmt.Errorf("idcodec: unknown codec %s", name)
+}
+
+// SetDefault replaces the codec used by Encode, Decode and the struct tags.
+func SetDefault(c Codec) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	current = c
+}
+
+// Default returns the codec used by Encode, Decode and the struct tags.
+func Default() Codec {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return current
+}
+
+// Encode encodes id with the default codec.
+func Encode(id int64) (string, error) {
+	return Default().Encode(id)
+}
+
+// Decode decodes s with the default codec.
+func Decode(s string) (int64, error) {
+	return Default().Decode(s)
+}
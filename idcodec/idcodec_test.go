@@ -0,0 +1,49 @@
+package idcodec
+
+import (
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	ids := []int64{0, 1, 7, 99, 100, 12345, 1<<31 - 1, 1 << 32, 1<<63 - 1}
+
+	for _, name := range []string{"hashids", "feistel"} {
+		c, err := New(name, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		other, _ := New(name, "other")
+		for _, id := range ids {
+			s, err := c.Encode(id)
+			if err != nil {
+				t.Errorf("%s encode %d: %v", name, id, err)
+				continue
+			}
+
+			if got, err := c.Decode(s); err != nil || got != id {
+				t.Errorf("%s decode %s: got %d %v, want %d", name, s, got, err, id)
+			}
+
+			if o, _ := other.Encode(id); o == s && id > 1 {
+				t.Errorf("%s encodes %d as %s with another secret", name, id, s)
+			}
+		}
+
+		if _, err := c.Encode(-1); err != ErrInvalidID {
+			t.Errorf("%s encode -1: got %v", name, err)
+		}
+
+		for _, s := range []string{"", "a", "!!!", "zzzzzzzzzzzzzzzz"} {
+			if id, err := c.Decode(s); err == nil {
+				if e, _ := c.Encode(id); e != s {
+					t.Errorf("%s decode %q: got %d, not canonical", name, s, id)
+				}
+			}
+		}
+	}
+
+	if _, err := New("base64", ""); err == nil {
+		t.Error("unknown codec accepted")
+	}
+}
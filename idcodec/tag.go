@@ -0,0 +1,172 @@
+package idcodec
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TagName is the struct tag linking an opaque string field to its
+// numeric source field, for example
+//
+//	Id  int64  `json:"-"`
+//	IdE string `json:"id" idcodec:"Id"`
+const TagName = "idcodec"
+
+// FieldError reports a tagged field that cannot be decoded.
+type FieldError struct {
+	Field string
+	Value string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid id %s on %s", e.Value, e.Field)
+}
+
+// EncodeFields fills every tagged string field with the encoded value of
+// its source field, walking through pointers, slices, maps and structs.
+// Structs passed by value cannot be filled and return an error, use
+// Encoded for them.
+func EncodeFields(v interface{}) error {
+	return walk(reflect.ValueOf(v), func(s reflect.Value, sf reflect.StructField, src reflect.Value) error {
+		if !s.CanSet() {
+			return fmt.Errorf("idcodec: cannot set %s, the value is not addressable", fieldName(sf))
+		}
+
+		e, err := Encode(src.Int())
+		if err == nil {
+			s.SetString(e)
+		}
+
+		return err
+	})
+}
+
+// Encoded returns v with its tagged fields encoded by EncodeFields,
+// a struct passed by value is encoded in a copy.
+func Encoded(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	p := reflect.New(reflect.TypeOf(v))
+	p.Elem().Set(reflect.ValueOf(v))
+	if err := EncodeFields(p.Interface()); err != nil {
+		return nil, err
+	}
+
+	return p.Elem().Interface(), nil
+}
+
+// DecodeFields sets the source field of every non empty tagged
+// string field with its decoded value.
+func DecodeFields(v interface{}) error {
+	return walk(reflect.ValueOf(v), func(s reflect.Value, sf reflect.StructField, src reflect.Value) error {
+		if s.String() == "" || !src.CanSet() {
+			return nil
+		}
+
+		id, err := Decode(s.String())
+		if err != nil || src.OverflowInt(id) {
+			return &FieldError{Field: fieldName(sf), Value: s.String()}
+		}
+
+		src.SetInt(id)
+
+		return nil
+	})
+}
+
+// walk calls fn on the tagged fields of v. Values held by interfaces and
+// maps are not addressable, they are walked in a copy set back in place.
+func walk(v reflect.Value, fn func(s reflect.Value, sf reflect.StructField, src reflect.Value) error) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		if e := v.Elem(); v.Kind() == reflect.Interface && e.Kind() != reflect.Ptr && v.CanSet() {
+			cp := reflect.New(e.Type()).Elem()
+			cp.Set(e)
+			if err := walk(cp, fn); err != nil {
+				return err
+			}
+
+			v.Set(cp)
+
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walk(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := v.MapIndex(k)
+			if e.Kind() == reflect.Ptr {
+				if err := walk(e, fn); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			cp := reflect.New(e.Type()).Elem()
+			cp.Set(e)
+			if err := walk(cp, fn); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(k, cp)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+
+			f := v.Field(i)
+			if name, ok := sf.Tag.Lookup(TagName); ok && f.Kind() == reflect.String {
+				src := v.FieldByName(name)
+				if !src.IsValid() {
+					return fmt.Errorf("idcodec: %s has no source field %s", t.Name(), name)
+				}
+
+				switch src.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				default:
+					return fmt.Errorf("idcodec: source field %s.%s is not an int", t.Name(), name)
+				}
+
+				if err := fn(f, sf, src); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := walk(f, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+
+	return sf.Name
+}
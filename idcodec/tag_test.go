@@ -0,0 +1,61 @@
+package idcodec
+
+import (
+	"testing"
+)
+
+type tagged struct {
+	Id  int64  `json:"-"`
+	IdE string `json:"id" idcodec:"Id"`
+}
+
+type taggedParent struct {
+	Child    tagged   `json:"child"`
+	Children []tagged `json:"children"`
+}
+
+func TestEncoded(t *testing.T) {
+	e7, _ := Encode(7)
+	e8, _ := Encode(8)
+
+	tests := []struct {
+		name  string
+		v     interface{}
+		check func(v interface{}) bool
+	}{
+		{"pointer", &tagged{Id: 7}, func(v interface{}) bool { return v.(*tagged).IdE == e7 }},
+		{"value", tagged{Id: 7}, func(v interface{}) bool { return v.(tagged).IdE == e7 }},
+		{"slice", []tagged{{Id: 7}, {Id: 8}}, func(v interface{}) bool { s := v.([]tagged); return s[0].IdE == e7 && s[1].IdE == e8 }},
+		{"map", map[string]tagged{"a": {Id: 7}}, func(v interface{}) bool { return v.(map[string]tagged)["a"].IdE == e7 }},
+		{"interfaces", []interface{}{tagged{Id: 7}}, func(v interface{}) bool { return v.([]interface{})[0].(tagged).IdE == e7 }},
+		{"nested", taggedParent{Child: tagged{Id: 7}, Children: []tagged{{Id: 8}}}, func(v interface{}) bool {
+			p := v.(taggedParent)
+			return p.Child.IdE == e7 && p.Children[0].IdE == e8
+		}},
+	}
+
+	for _, tt := range tests {
+		v, err := Encoded(tt.v)
+		if err != nil || !tt.check(v) {
+			t.Errorf("%s: got %+v %v", tt.name, v, err)
+		}
+	}
+
+	if err := EncodeFields(tagged{Id: 7}); err == nil {
+		t.Error("EncodeFields of a value succeeded without encoding")
+	}
+}
+
+func TestDecodeFields(t *testing.T) {
+	e7, _ := Encode(7)
+
+	v := &taggedParent{Child: tagged{IdE: e7}, Children: []tagged{{IdE: e7}, {}}}
+	if err := DecodeFields(v); err != nil || v.Child.Id != 7 || v.Children[0].Id != 7 || v.Children[1].Id != 0 {
+		t.Errorf("got %+v %v", v, err)
+	}
+
+	err := DecodeFields(&tagged{IdE: "!"})
+	if fe, ok := err.(*FieldError); !ok || fe.Field != "id" {
+		t.Errorf("invalid id: got %v", err)
+	}
+}
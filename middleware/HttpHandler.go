@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo"
+	"github.com/qasico/cuxs/errors"
 	"github.com/qasico/cuxs/response"
 )
 
@@ -18,13 +19,17 @@ func HTTPHandler(err error, c echo.Context) {
 	if he, ok := err.(*echo.HTTPError); ok {
 		code = he.Code
 		r.Message = he.Message
+	} else if errors.Fill(&r, err) {
+		code = r.Code
 	}
 
+	r.Code = code
+
 	if !c.Response().Committed() {
 		if c.Request().Method() == "HEAD" {
 			c.NoContent(code)
 		} else {
-			c.JSON(code, r)
+			response.Render(c, code, r)
 		}
 	}
 }
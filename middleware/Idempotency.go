@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/qasico/cuxs/response"
+)
+
+type (
+	// IdempotencyConfig defines the config for Idempotency middleware.
+	IdempotencyConfig struct {
+		// Store keeps the requests and their responses.
+		Store IdempotencyStore
+
+		// TTL is how long a response is replayed, default to 24 hours.
+		TTL time.Duration
+
+		// LockTTL is how long a request in progress holds its key, so the
+		// key is freed when the process dies mid-request, default to one
+		// minute. It should exceed the longest request.
+		LockTTL time.Duration
+
+		// Methods honoring the key, default to POST and PATCH.
+		Methods []string
+
+		// Skipper allows to skip the middleware for the request.
+		Skipper func(c echo.Context) bool
+	}
+)
+
+const (
+	// HeaderIdempotencyKey is the request header holding the client key.
+	HeaderIdempotencyKey = "Idempotency-Key"
+
+	// HeaderIdempotentReplayed is set on the replayed responses.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// Idempotency returns a middleware that replays the stored response of
+// the requests repeating an Idempotency-Key header.
+func Idempotency(store IdempotencyStore) echo.MiddlewareFunc {
+	return IdempotencyWithConfig(IdempotencyConfig{Store: store})
+}
+
+// IdempotencyWithConfig returns an Idempotency middleware from config.
+// A repeated key with the same request replays the first response, with a
+// different request it is rejected with 422, and while the first request
+// is in progress with 409. Server errors are not kept so they can be retried.
+func IdempotencyWithConfig(config IdempotencyConfig) echo.MiddlewareFunc {
+	if config.Store == nil {
+		panic("idempotency middleware requires a store")
+	}
+
+	if config.TTL == 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	if config.LockTTL == 0 {
+		config.LockTTL = time.Minute
+	}
+
+	if len(config.Methods) == 0 {
+		config.Methods = []string{echo.POST, echo.PATCH}
+	}
+
+	return func(n echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header().Get(HeaderIdempotencyKey)
+			if key == "" || !hasMethod(config.Methods, req.Method()) || (config.Skipper != nil && config.Skipper(c)) {
+				return n(c)
+			}
+
+			if len(key) > 128 {
+				return idempotencyError(c, response.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must not exceed 128 characters")
+			}
+
+			body, err := ioutil.ReadAll(req.Body())
+			if err != nil {
+				return err
+			}
+
+			req.SetBody(bytes.NewReader(body))
+
+			rec := &IdempotencyRecord{
+				Key:         scopeKey(req.Header().Get(echo.HeaderAuthorization), key),
+				Fingerprint: fingerprint(req.Method(), req.URI(), body),
+				ExpiresAt:   time.Now().Add(config.LockTTL),
+			}
+
+			prev, err := config.Store.Begin(rec)
+			if err != nil {
+				return err
+			}
+
+			if prev != nil {
+				return replay(c, rec, prev)
+			}
+
+			defer func() {
+				if p := recover(); p != nil {
+					config.Store.Release(rec.Key)
+					panic(p)
+				}
+			}()
+
+			res := c.Response()
+			buf := new(bytes.Buffer)
+			w := res.Writer()
+			res.SetWriter(io.MultiWriter(w, buf))
+			defer res.SetWriter(w)
+
+			// the error is rendered here to keep its response
+			if err = n(c); err != nil {
+				c.Error(err)
+			}
+
+			if res.Status() >= response.StatusInternalServerError {
+				return config.Store.Release(rec.Key)
+			}
+
+			rec.Done = true
+			rec.Status = res.Status()
+			rec.ContentType = res.Header().Get(echo.HeaderContentType)
+			rec.Body = buf.Bytes()
+			rec.ExpiresAt = time.Now().Add(config.TTL)
+
+			return config.Store.Complete(rec)
+		}
+	}
+}
+
+// replay writes the response of prev, or the conflict of rec with prev.
+func replay(c echo.Context, rec *IdempotencyRecord, prev *IdempotencyRecord) error {
+	switch {
+	case prev.Fingerprint != rec.Fingerprint:
+		return idempotencyError(c, response.StatusUnprocessableEntry, "idempotency_key_reused", "Idempotency-Key was used with a different request")
+	case !prev.Done:
+		return idempotencyError(c, response.StatusConflict, "idempotency_key_in_progress", "A request with the same Idempotency-Key is in progress")
+	}
+
+	res := c.Response()
+	if prev.ContentType != "" {
+		res.Header().Set(echo.HeaderContentType, prev.ContentType)
+	}
+
+	res.Header().Set(HeaderIdempotentReplayed, "true")
+	res.WriteHeader(prev.Status)
+	_, err := res.Write(prev.Body)
+
+	return err
+}
+
+func idempotencyError(c echo.Context, code int, errorCode string, message string) error {
+	r := response.Attribute{
+		Code:      code,
+		Status:    response.StatusFailed,
+		Message:   message,
+		ErrorCode: errorCode,
+	}
+
+	return response.Render(c, r.Code, r)
+}
+
+// scopeKey binds the client key to the credentials of the request,
+// so a key cannot replay the response of another client.
+func scopeKey(auth string, key string) string {
+	if auth == "" {
+		return key
+	}
+
+	h := sha256.Sum256([]byte(auth))
+
+	return hex.EncodeToString(h[:8]) + ":" + key
+}
+
+func fingerprint(method string, uri string, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, method+" "+uri+"\n")
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/jinzhu/gorm"
+	"github.com/qasico/cuxs/helper"
+)
+
+type (
+	// IdempotencyRecord is a request kept by its Idempotency-Key,
+	// with its response once Done.
+	IdempotencyRecord struct {
+		Key         string    `gorm:"column:idempotency_key;primary_key;size:191" json:"key"`
+		Fingerprint string    `gorm:"size:64" json:"fingerprint"`
+		Done        bool      `json:"done"`
+		Status      int       `json:"status"`
+		ContentType string    `json:"content_type"`
+		Body        []byte    `json:"body"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+
+	// IdempotencyStore keeps the records of the Idempotency middleware.
+	IdempotencyStore interface {
+		// Begin saves rec unless its key is kept, then it returns the kept record.
+		Begin(rec *IdempotencyRecord) (*IdempotencyRecord, error)
+		Complete(rec *IdempotencyRecord) error
+		Release(key string) error
+	}
+
+	// MemoryIdempotencyStore is an IdempotencyStore kept in process memory.
+	MemoryIdempotencyStore struct {
+		mutex   sync.Mutex
+		records map[string]IdempotencyRecord
+	}
+
+	// SQLIdempotencyStore is an IdempotencyStore kept in the idempotency_keys table.
+	SQLIdempotencyStore struct {
+		DB *gorm.DB
+	}
+
+	// RedisIdempotencyStore is an IdempotencyStore kept in redis.
+	RedisIdempotencyStore struct {
+		Pool   *redis.Pool
+		Prefix string
+	}
+)
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+func (rec *IdempotencyRecord) expired() bool {
+	return rec.ExpiresAt.Before(time.Now())
+}
+
+// NewMemoryIdempotencyStore returns an empty in-memory IdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+}
+
+func (m *MemoryIdempotencyStore) Begin(rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.gc()
+	if prev, ok := m.records[rec.Key]; ok {
+		return &prev, nil
+	}
+
+	m.records[rec.Key] = *rec
+
+	return nil, nil
+}
+
+func (m *MemoryIdempotencyStore) Complete(rec *IdempotencyRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.records[rec.Key] = *rec
+
+	return nil
+}
+
+func (m *MemoryIdempotencyStore) Release(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.records, key)
+
+	return nil
+}
+
+// gc removes expired records, must be called with the lock held.
+func (m *MemoryIdempotencyStore) gc() {
+	for k, rec := range m.records {
+		if rec.expired() {
+			delete(m.records, k)
+		}
+	}
+}
+
+// NewSQLIdempotencyStore returns an IdempotencyStore kept in db,
+// usually cuxs.ORM(), creating its table when missing.
+func NewSQLIdempotencyStore(db *gorm.DB) (*SQLIdempotencyStore, error) {
+	if err := db.AutoMigrate(&IdempotencyRecord{}).Error; err != nil {
+		return nil, err
+	}
+
+	return &SQLIdempotencyStore{DB: db}, nil
+}
+
+func (s *SQLIdempotencyStore) Begin(rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	if err := s.DB.Where("expires_at < ?", time.Now()).Delete(&IdempotencyRecord{}).Error; err != nil {
+		return nil, err
+	}
+
+	// the primary key makes the insert fail for a kept key
+	err := s.DB.Create(rec).Error
+	if err == nil {
+		return nil, nil
+	}
+
+	prev := new(IdempotencyRecord)
+	if s.DB.Where("idempotency_key = ?", rec.Key).First(prev).Error != nil {
+		return nil, err
+	}
+
+	return prev, nil
+}
+
+func (s *SQLIdempotencyStore) Complete(rec *IdempotencyRecord) error {
+	return s.DB.Save(rec).Error
+}
+
+func (s *SQLIdempotencyStore) Release(key string) error {
+	return s.DB.Where("idempotency_key = ?", key).Delete(&IdempotencyRecord{}).Error
+}
+
+// NewRedisIdempotencyStore returns an IdempotencyStore connected with the
+// given redis config, usually taken from cuxs.Config.RedisConfig.
+func NewRedisIdempotencyStore(network string, address string, password string) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{
+		Prefix: "cuxs:idempotency:",
+		Pool:   helper.RedisPool(network, address, password),
+	}
+}
+
+// begin sets the record unless its key is kept, then it returns the kept
+// record. Both run in one script so the kept record can not expire between.
+var begin = redis.NewScript(1, `
+if redis.call("SET", KEYS[1], ARGV[1], "EX", ARGV[2], "NX") then return false end
+return redis.call("GET", KEYS[1])`)
+
+func (r *RedisIdempotencyStore) Begin(rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	c := r.Pool.Get()
+	defer c.Close()
+
+	pb, err := redis.Bytes(begin.Do(c, r.Prefix+rec.Key, b, ttlOf(rec)))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	prev := new(IdempotencyRecord)
+	if err = json.Unmarshal(pb, prev); err != nil {
+		return nil, err
+	}
+
+	return prev, nil
+}
+
+func (r *RedisIdempotencyStore) Complete(rec *IdempotencyRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	c := r.Pool.Get()
+	defer c.Close()
+
+	_, err = c.Do("SET", r.Prefix+rec.Key, b, "EX", ttlOf(rec))
+
+	return err
+}
+
+func (r *RedisIdempotencyStore) Release(key string) error {
+	c := r.Pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("DEL", r.Prefix+key)
+
+	return err
+}
+
+// ttlOf returns the seconds left to the record, at least one.
+func ttlOf(rec *IdempotencyRecord) int64 {
+	ttl := int64(rec.ExpiresAt.Sub(time.Now()) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	return ttl
+}
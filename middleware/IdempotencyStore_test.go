@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// testIdempotencyStore runs the IdempotencyStore contract on s,
+// expiry is checked when the store removes expired records at once.
+func testIdempotencyStore(t *testing.T, s IdempotencyStore, expiry bool) {
+	rec := func(key string, ttl time.Duration) *IdempotencyRecord {
+		return &IdempotencyRecord{Key: key, Fingerprint: "f", ExpiresAt: time.Now().Add(ttl)}
+	}
+
+	if prev, err := s.Begin(rec("a", time.Minute)); prev != nil || err != nil {
+		t.Fatalf("begin new key: got %v %v", prev, err)
+	}
+
+	if prev, err := s.Begin(rec("a", time.Minute)); err != nil || prev == nil || prev.Done || prev.Fingerprint != "f" {
+		t.Fatalf("begin kept key: got %+v %v, want the record in progress", prev, err)
+	}
+
+	done := rec("a", time.Hour)
+	done.Done, done.Status, done.ContentType, done.Body = true, 201, "text/plain", []byte("ok")
+	if err := s.Complete(done); err != nil {
+		t.Fatal(err)
+	}
+
+	if prev, err := s.Begin(rec("a", time.Minute)); err != nil || prev == nil || !prev.Done || prev.Status != 201 || string(prev.Body) != "ok" {
+		t.Fatalf("begin completed key: got %+v %v, want the response", prev, err)
+	}
+
+	if err := s.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if prev, err := s.Begin(rec("a", time.Minute)); prev != nil || err != nil {
+		t.Fatalf("begin released key: got %v %v", prev, err)
+	}
+
+	if expiry {
+		s.Begin(rec("b", -time.Second))
+		if prev, err := s.Begin(rec("b", time.Minute)); prev != nil || err != nil {
+			t.Fatalf("begin expired key: got %v %v", prev, err)
+		}
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	testIdempotencyStore(t, NewMemoryIdempotencyStore(), true)
+}
+
+func TestSQLIdempotencyStore(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s, err := NewSQLIdempotencyStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testIdempotencyStore(t, s, true)
+}
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDRESS")
+	if addr == "" {
+		t.Skip("REDIS_ADDRESS is not set")
+	}
+
+	s := NewRedisIdempotencyStore("tcp", addr, os.Getenv("REDIS_PASS"))
+	s.Prefix = "cuxs:test:idempotency:"
+	s.Release("a")
+
+	testIdempotencyStore(t, s, false)
+}
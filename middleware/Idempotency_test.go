@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
+)
+
+// lockStore keeps the expiry of the records saved by Begin and Complete.
+type lockStore struct {
+	*MemoryIdempotencyStore
+	begun, completed time.Time
+}
+
+func (s *lockStore) Begin(rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.begun = rec.ExpiresAt
+	return s.MemoryIdempotencyStore.Begin(rec)
+}
+
+func (s *lockStore) Complete(rec *IdempotencyRecord) error {
+	s.completed = rec.ExpiresAt
+	return s.MemoryIdempotencyStore.Complete(rec)
+}
+
+func serveIdempotent(e *echo.Echo, h echo.HandlerFunc, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	req.Header.Set(HeaderIdempotencyKey, key)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(standard.NewRequest(req, e.Logger()), standard.NewResponse(rec, e.Logger()))
+
+	if err := h(c); err != nil {
+		c.Error(err)
+	}
+
+	return rec
+}
+
+func TestIdempotency(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	store.Begin(&IdempotencyRecord{Key: "running", Fingerprint: fingerprint("POST", "/orders", []byte("x")), ExpiresAt: time.Now().Add(time.Minute)})
+	store.Begin(&IdempotencyRecord{Key: "crashed", Fingerprint: fingerprint("POST", "/orders", []byte("x")), ExpiresAt: time.Now().Add(-time.Second)})
+
+	calls := 0
+	e := echo.New()
+	h := Idempotency(store)(func(c echo.Context) error {
+		calls++
+		if c.Request().Header().Get(HeaderIdempotencyKey) == "fail" {
+			return echo.NewHTTPError(500)
+		}
+
+		return c.String(201, strconv.Itoa(calls))
+	})
+
+	tests := []struct {
+		key, body string
+		code      int
+		replayed  bool
+		calls     int
+	}{
+		{"a", "x", 201, false, 1},
+		{"a", "x", 201, true, 1},
+		{"a", "y", 422, false, 1},
+		{"fail", "x", 500, false, 2},
+		{"fail", "x", 500, false, 3},
+		{"running", "x", 409, false, 3},
+		{"crashed", "x", 201, false, 4},
+		{strings.Repeat("k", 129), "x", 400, false, 4},
+		{"", "x", 201, false, 5},
+		{"", "x", 201, false, 6},
+	}
+
+	for _, tt := range tests {
+		rec := serveIdempotent(e, h, tt.key, tt.body)
+		if rec.Code != tt.code || (rec.Header().Get(HeaderIdempotentReplayed) == "true") != tt.replayed || calls != tt.calls {
+			t.Errorf("key %.10s body %s: got %d replayed %q after %d calls, want %d %v after %d", tt.key, tt.body, rec.Code, rec.Header().Get(HeaderIdempotentReplayed), calls, tt.code, tt.replayed, tt.calls)
+		}
+
+		if tt.replayed && rec.Body.String() != "1" {
+			t.Errorf("key %s: replayed %q, want the first response", tt.key, rec.Body.String())
+		}
+	}
+}
+
+func TestIdempotencyLockTTL(t *testing.T) {
+	store := &lockStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore()}
+	h := IdempotencyWithConfig(IdempotencyConfig{Store: store, TTL: time.Hour, LockTTL: time.Second})(func(c echo.Context) error {
+		return c.NoContent(201)
+	})
+
+	now := time.Now()
+	serveIdempotent(echo.New(), h, "a", "x")
+
+	if d := store.begun.Sub(now); d < 0 || d > 2*time.Second {
+		t.Errorf("in progress record expires in %s, want the lock ttl", d)
+	}
+
+	if d := store.completed.Sub(now); d < time.Hour-time.Minute || d > time.Hour+time.Minute {
+		t.Errorf("completed record expires in %s, want the ttl", d)
+	}
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/qasico/cuxs/response"
+)
+
+type (
+	// JWTConfig defines the config for JWT middleware.
+	JWTConfig struct {
+		// Key used to verify the token signature, a []byte for HMAC
+		// methods or the public key for RSA and ECDSA methods.
+		Key interface{}
+
+		// SigningMethod expected on the token, default to HS256.
+		SigningMethod string
+
+		// Issuer and Audience are required on the token when not empty.
+		Issuer   string
+		Audience string
+
+		// ContextKey is the echo.Context key to store the claims, default to "user".
+		ContextKey string
+
+		// SkipPaths lists route paths that are served without token.
+		SkipPaths []string
+
+		// Skipper allows to skip the middleware for the request.
+		Skipper func(c echo.Context) bool
+	}
+)
+
+const (
+	bearer = "Bearer"
+
+	// refreshTokenType is the typ claim of auth.RefreshTokenType.
+	refreshTokenType = "refresh"
+
+	// DefaultJWTContextKey is the default key used to store the token claims.
+	DefaultJWTContextKey = "user"
+)
+
+// JWT returns a middleware that requires a valid HS256 bearer token
+// signed with key, for example cuxs.JwtKey().
+func JWT(key []byte) echo.MiddlewareFunc {
+	return JWTWithConfig(JWTConfig{Key: key})
+}
+
+// JWTWithConfig returns a JWT middleware from config.
+func JWTWithConfig(config JWTConfig) echo.MiddlewareFunc {
+	if config.Key == nil {
+		panic("jwt middleware requires signing key")
+	}
+
+	if config.SigningMethod == "" {
+		config.SigningMethod = jwt.SigningMethodHS256.Alg()
+	}
+
+	if config.ContextKey == "" {
+		config.ContextKey = DefaultJWTContextKey
+	}
+
+	return func(n echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.skip(c) {
+				return n(c)
+			}
+
+			claims, err := config.parse(c.Request().Header().Get(echo.HeaderAuthorization))
+			if err != nil {
+				return unauthorized(c, err)
+			}
+
+			c.Set(config.ContextKey, claims)
+
+			return n(c)
+		}
+	}
+}
+
+// JWTClaims returns the token claims stored by the JWT middleware
+// under the default context key.
+func JWTClaims(c echo.Context) jwt.MapClaims {
+	return ClaimsOf(c, DefaultJWTContextKey)
+}
+
+// ClaimsOf returns the token claims stored by the JWT middleware
+// under the context key of its config.
+func ClaimsOf(c echo.Context, key string) jwt.MapClaims {
+	if claims, ok := c.Get(key).(jwt.MapClaims); ok {
+		return claims
+	}
+
+	return nil
+}
+
+func (config *JWTConfig) skip(c echo.Context) bool {
+	if config.Skipper != nil && config.Skipper(c) {
+		return true
+	}
+
+	for _, p := range config.SkipPaths {
+		if p == c.Path() || p == c.Request().URL().Path() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (config *JWTConfig) parse(auth string) (jwt.MapClaims, error) {
+	l := len(bearer)
+	if len(auth) <= l+1 || auth[:l] != bearer {
+		return nil, fmt.Errorf("missing or malformed token")
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(auth[l+1:], claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != config.SigningMethod {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+
+		return config.Key, nil
+	})
+
+	// signature, exp and nbf are checked by the parser, but only
+	// when present, a token without exp would never expire
+	if err != nil || !token.Valid || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	if config.Issuer != "" && !claims.VerifyIssuer(config.Issuer, true) {
+		return nil, fmt.Errorf("invalid token issuer")
+	}
+
+	if config.Audience != "" && !hasAudience(claims["aud"], config.Audience) {
+		return nil, fmt.Errorf("invalid token audience")
+	}
+
+	// refresh tokens of the auth package are only accepted by Issuer.Refresh
+	if typ, _ := claims["typ"].(string); typ == refreshTokenType {
+		return nil, fmt.Errorf("refresh token cannot be used as access token")
+	}
+
+	return claims, nil
+}
+
+func hasAudience(aud interface{}, expected string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == expected
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == expected {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func unauthorized(c echo.Context, err error) error {
+	r := response.Attribute{
+		Code:    response.StatusUnauthorized,
+		Status:  response.StatusFailed,
+		Message: response.StatusText(response.StatusUnauthorized),
+	}
+	r.SetError("token", err.Error())
+
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, bearer+` realm="Restricted"`)
+
+	return response.Render(c, r.Code, r)
+}
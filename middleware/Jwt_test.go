@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
+)
+
+func serveJWT(e *echo.Echo, h echo.HandlerFunc, path string, claims jwt.MapClaims, key []byte) int {
+	req := httptest.NewRequest("GET", path, nil)
+	if claims != nil {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(standard.NewRequest(req, e.Logger()), standard.NewResponse(rec, e.Logger()))
+
+	h(c)
+
+	return rec.Code
+}
+
+func TestJWTWithConfig(t *testing.T) {
+	key := []byte("secret")
+	now := time.Now()
+	exp := now.Add(time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		path   string
+		claims jwt.MapClaims
+		key    []byte
+		code   int
+	}{
+		{"valid", "/", jwt.MapClaims{"sub": "1", "exp": exp, "iss": "cuxs", "aud": "api"}, key, 200},
+		{"audience list", "/", jwt.MapClaims{"sub": "1", "exp": exp, "iss": "cuxs", "aud": []string{"web", "api"}}, key, 200},
+		{"missing token", "/", nil, key, 401},
+		{"wrong key", "/", jwt.MapClaims{"sub": "1", "exp": exp, "iss": "cuxs", "aud": "api"}, []byte("other"), 401},
+		{"expired", "/", jwt.MapClaims{"sub": "1", "exp": now.Add(-time.Minute).Unix(), "iss": "cuxs", "aud": "api"}, key, 401},
+		{"without exp", "/", jwt.MapClaims{"sub": "1", "iss": "cuxs", "aud": "api"}, key, 401},
+		{"not before", "/", jwt.MapClaims{"sub": "1", "exp": exp, "nbf": now.Add(time.Minute).Unix(), "iss": "cuxs", "aud": "api"}, key, 401},
+		{"wrong issuer", "/", jwt.MapClaims{"sub": "1", "exp": exp, "iss": "other", "aud": "api"}, key, 401},
+		{"wrong audience", "/", jwt.MapClaims{"sub": "1", "exp": exp, "iss": "cuxs", "aud": "web"}, key, 401},
+		{"skip path", "/health", nil, key, 200},
+	}
+
+	e := echo.New()
+	h := JWTWithConfig(JWTConfig{Key: key, Issuer: "cuxs", Audience: "api", SkipPaths: []string{"/health"}})(func(c echo.Context) error {
+		return c.NoContent(200)
+	})
+
+	for _, tt := range tests {
+		if code := serveJWT(e, h, tt.path, tt.claims, tt.key); code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.name, code, tt.code)
+		}
+	}
+}
+
+func TestJWTRejectsRefreshToken(t *testing.T) {
+	key := []byte("secret")
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		claims jwt.MapClaims
+		code   int
+	}{
+		{jwt.MapClaims{"sub": "1", "exp": exp}, 200},
+		{jwt.MapClaims{"sub": "1", "exp": exp, "typ": "refresh"}, 401},
+	}
+
+	e := echo.New()
+	h := JWT(key)(func(c echo.Context) error {
+		return c.NoContent(200)
+	})
+
+	for _, tt := range tests {
+		if code := serveJWT(e, h, "/", tt.claims, key); code != tt.code {
+			t.Errorf("claims %v: got %d, want %d", tt.claims, code, tt.code)
+		}
+	}
+}
@@ -62,3 +62,16 @@ func openConnection(c DatabaseConfig, dbname string) (conn string) {
 func ORM() *gorm.DB {
 	return Orm[Config.DatabaseConfig.DBName]
 }
+
+// CloseDB closes every database connection opened by NewDB.
+func CloseDB() {
+	for name, orm := range Orm {
+		if err := orm.Close(); err != nil {
+			log.Errorf("Cannot close database %s, %s", name, err.Error())
+		}
+
+		delete(Orm, name)
+	}
+
+	DB = nil
+}
@@ -0,0 +1,134 @@
+package cuxs
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/qasico/cuxs/response"
+)
+
+// setPagination fills the response meta and the Link header
+// of a list response from the query param.
+func (h *Handler) setPagination() {
+	q := h.QueryParam
+	if q == nil || h.Context.Request().Method() != "GET" || q.Limit < 1 {
+		return
+	}
+
+	size, ok := listLen(h.Response.Data)
+	if !ok {
+		return
+	}
+
+	m := &response.Meta{PerPage: q.Limit}
+	links := map[string]string{}
+
+	if q.CursorMode {
+		if q.NextCursor != "" {
+			links["next"] = h.pageURL("cursor", q.NextCursor)
+		}
+
+		if q.PrevCursor != "" {
+			links["prev"] = h.pageURL("cursor", q.PrevCursor)
+		}
+	} else {
+		m.Page = q.Page
+		if q.Page > 1 {
+			links["first"] = h.pageURL("page", "1")
+			links["prev"] = h.pageURL("page", strconv.Itoa(q.Page-1))
+		}
+
+		if q.Count || h.Response.Total > 0 {
+			total := h.Response.Total
+			pages := int((total + int64(q.Limit) - 1) / int64(q.Limit))
+			m.Total, m.TotalPages = &total, &pages
+
+			if q.Page < pages {
+				links["next"] = h.pageURL("page", strconv.Itoa(q.Page+1))
+			}
+
+			if pages > 0 {
+				links["last"] = h.pageURL("page", strconv.Itoa(pages))
+			}
+		} else if size >= q.Limit {
+			links["next"] = h.pageURL("page", strconv.Itoa(q.Page+1))
+		}
+	}
+
+	m.Next, m.Prev = links["next"], links["prev"]
+	h.Response.Meta = m
+
+	var header []string
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if l, ok := links[rel]; ok {
+			header = append(header, fmt.Sprintf(`<%s>; rel="%s"`, l, rel))
+		}
+	}
+
+	if len(header) > 0 {
+		h.Context.Response().Header().Set("Link", strings.Join(header, ", "))
+	}
+}
+
+// pageURL returns the request url with the query string key set to value.
+func (h *Handler) pageURL(key string, value string) string {
+	req := h.Context.Request()
+	qs := url.Values{}
+	for k, v := range h.Context.QueryParams() {
+		qs[k] = v
+	}
+
+	qs.Set(key, value)
+	if key == "cursor" {
+		qs.Del("page")
+	}
+
+	return fmt.Sprintf("%s://%s%s?%s", req.Scheme(), req.Host(), req.URL().Path(), qs.Encode())
+}
+
+// listLen returns the length of d when it is a slice or array,
+// looking through pointers and interfaces.
+func listLen(d interface{}) (int, bool) {
+	v := reflect.ValueOf(d)
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+
+	if v.IsValid() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		return v.Len(), true
+	}
+
+	return 0, false
+}
+
+// PageSize holds the default and maximum per_page of a route.
+type PageSize struct {
+	Default int
+	Max     int
+}
+
+const pageSizeKey = "cuxs.page_size"
+
+// WithPageSize returns a middleware overriding the default and maximum
+// per_page set by APP_PERPAGE and APP_MAX_PERPAGE for the routes it wraps.
+func WithPageSize(def int, max int) echo.MiddlewareFunc {
+	return func(n echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(pageSizeKey, PageSize{Default: def, Max: max})
+
+			return n(c)
+		}
+	}
+}
+
+func pageSizeOf(c echo.Context) PageSize {
+	if s, ok := c.Get(pageSizeKey).(PageSize); ok {
+		return s
+	}
+
+	return PageSize{Default: Config.DefaultPerPage, Max: Config.MaxPerPage}
+}
@@ -0,0 +1,67 @@
+package cuxs
+
+import "testing"
+
+func TestSetPagination(t *testing.T) {
+	tests := []struct {
+		method, target string
+		items          int
+		total          int64
+		next           string
+		page           int
+		pages          int
+		link           string
+	}{
+		{"GET", "/items?per_page=2", 2, 0, "", 1, 0,
+			`<http://example.com/items?page=2&per_page=2>; rel="next"`},
+		{"GET", "/items?per_page=2", 1, 0, "", 1, 0, ""},
+		{"GET", "/items?count=true&page=2&per_page=2", 2, 5, "", 2, 3,
+			`<http://example.com/items?count=true&page=1&per_page=2>; rel="first", ` +
+				`<http://example.com/items?count=true&page=1&per_page=2>; rel="prev", ` +
+				`<http://example.com/items?count=true&page=3&per_page=2>; rel="next", ` +
+				`<http://example.com/items?count=true&page=3&per_page=2>; rel="last"`},
+		{"GET", "/items?count=true&page=3&per_page=2", 1, 5, "", 3, 3,
+			`<http://example.com/items?count=true&page=1&per_page=2>; rel="first", ` +
+				`<http://example.com/items?count=true&page=2&per_page=2>; rel="prev", ` +
+				`<http://example.com/items?count=true&page=3&per_page=2>; rel="last"`},
+		{"GET", "/items?cursor=&page=2&per_page=2", 2, 0, "n", 0, 0,
+			`<http://example.com/items?cursor=n&per_page=2>; rel="next"`},
+		{"POST", "/items?per_page=2", 2, 0, "", 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		c, rec := newTestContext(tt.method, tt.target, "", "")
+		h, err := NewHandler(c, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h.Response.SetData(make([]int, tt.items))
+		h.Response.Total = tt.total
+		if h.QueryParam != nil {
+			h.QueryParam.NextCursor = tt.next
+		}
+		h.setPagination()
+
+		if link := rec.Header().Get("Link"); link != tt.link {
+			t.Errorf("%s %s: link %q, want %q", tt.method, tt.target, link, tt.link)
+		}
+
+		m := h.Response.Meta
+		if tt.method != "GET" {
+			if m != nil {
+				t.Errorf("%s %s: meta %+v, want none", tt.method, tt.target, m)
+			}
+			continue
+		}
+
+		pages := 0
+		if m.TotalPages != nil {
+			pages = *m.TotalPages
+		}
+
+		if m.Page != tt.page || m.PerPage != 2 || pages != tt.pages || (m.Total != nil) != (tt.total > 0) {
+			t.Errorf("%s: meta %+v, want page %d of %d", tt.target, m, tt.page, tt.pages)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package cuxs
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/structs"
+	"github.com/jinzhu/gorm"
+	"github.com/qasico/cuxs/helper"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	// readonlyColumns are maintained by gorm and cannot be patched.
+	readonlyColumns = map[string]bool{"created_at": true, "updated_at": true, "deleted_at": true}
+
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// patchOperation is an operation of a RFC 6902 JSON Patch document.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// UpdateModel applies the fields submitted by the client to the loaded model
+// and saves only those columns. A plain json or a JSON Merge Patch body
+// updates the keys present in the body, a JSON Patch body applies its
+// operations. Explicit nulls set pointer and sql.Null* columns to NULL,
+// absent keys are kept. Only the fields of the request struct can be
+// changed, never the primary key and the gorm timestamps.
+func (h *Handler) UpdateModel(db *gorm.DB, model interface{}) error {
+	s := newModelSchema(db, model)
+	mv := reflect.Indirect(reflect.ValueOf(model))
+
+	doc := map[string]interface{}{}
+	for name, column := range s.names {
+		doc[name] = mv.FieldByName(s.fields[column]).Interface()
+	}
+
+	changed, err := h.patchDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	updates := map[string]interface{}{}
+	for _, k := range changed {
+		column, ok := s.names[k]
+		if !ok || !h.allowedKey(k) {
+			h.setRuleError(k, "unknown", "", reflect.Invalid)
+			continue
+		}
+
+		if column == s.scope.PrimaryKey() || readonlyColumns[column] {
+			h.setRuleError(k, "readonly", "", reflect.Invalid)
+			continue
+		}
+
+		f := mv.FieldByName(s.fields[column])
+		v, present := doc[k]
+		if !present || v == nil {
+			if !nullable(f.Type()) {
+				h.setRuleError(k, "null", "", reflect.Invalid)
+				continue
+			}
+
+			f.Set(reflect.Zero(f.Type()))
+			updates[column] = nil
+			keys = append(keys, k)
+			continue
+		}
+
+		b, _ := json.Marshal(v)
+		p := reflect.New(f.Type())
+		if err := json.Unmarshal(b, p.Interface()); err != nil {
+			h.setRuleError(k, "type", typeName(f.Type()), reflect.Invalid)
+			continue
+		}
+
+		f.Set(p.Elem())
+		updates[column] = f.Interface()
+		keys = append(keys, k)
+	}
+
+	if len(h.Response.Errors) == 0 && strings.HasPrefix(h.Context.Request().Header().Get("Content-Type"), MIMEJSONPatch) {
+		h.validatePatch(doc, keys)
+	}
+
+	if len(h.Response.Errors) > 0 {
+		return errValidation
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return db.Model(model).Updates(updates).Error
+}
+
+// patchDocument applies the request body to doc and returns the changed keys.
+func (h *Handler) patchDocument(doc map[string]interface{}) ([]string, error) {
+	ct := h.Context.Request().Header().Get("Content-Type")
+
+	if strings.HasPrefix(ct, MIMEJSONPatch) {
+		var ops []patchOperation
+		if err := json.Unmarshal(h.body, &ops); err != nil {
+			h.setRuleError("patch", "invalid", "", reflect.Invalid)
+			return nil, errValidation
+		}
+
+		var changed []string
+		for i, op := range ops {
+			if err := applyOperation(doc, op); err != nil {
+				h.setParamErrorOf(fmt.Sprintf("patch[%d]", i), err)
+				return nil, errValidation
+			}
+
+			changed = append(changed, rootKey(op.Path))
+			if op.Op == "move" {
+				changed = append(changed, rootKey(op.From))
+			}
+		}
+
+		return changed, nil
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(h.body, &body); err != nil {
+		h.setRuleError("body", "invalid", "", reflect.Invalid)
+		return nil, errValidation
+	}
+
+	var changed []string
+	for k, raw := range body {
+		var v interface{}
+		json.Unmarshal(raw, &v)
+
+		// RFC 7396 merges objects recursively, plain json replaces them
+		if strings.HasPrefix(ct, MIMEMergePatch) {
+			v = mergePatch(doc[k], v)
+		}
+
+		doc[k] = v
+		changed = append(changed, k)
+	}
+
+	return changed, nil
+}
+
+// validatePatch runs the rules of the request fields changed by a JSON
+// Patch body, which Prepare can neither bind nor validate.
+func (h *Handler) validatePatch(doc map[string]interface{}, keys []string) {
+	if h.RequestHandler == nil || len(keys) == 0 {
+		return
+	}
+
+	t := reflect.TypeOf(*h.RequestHandler)
+	if t.Kind() != reflect.Ptr {
+		return
+	}
+
+	values := map[string]interface{}{}
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		values[k] = doc[k]
+		fields[i] = helper.CamelCase(k)
+	}
+
+	req := reflect.New(t.Elem()).Interface()
+	b, _ := json.Marshal(values)
+	json.Unmarshal(b, req)
+
+	if err := h.validate(req, fields...); err != nil {
+		h.ValidationError(err.(validator.ValidationErrors))
+	}
+}
+
+// allowedKey checks that the json key is a field of the request struct,
+// no key is allowed without request struct.
+func (h *Handler) allowedKey(k string) bool {
+	if h.RequestHandler == nil {
+		return false
+	}
+
+	_, ok := structs.Map(*h.RequestHandler)[helper.CamelCase(k)]
+
+	return ok
+}
+
+// nullable reports whether the field type can hold a NULL column,
+// pointers and sql.Null* like types.
+func nullable(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr || (t.Implements(valuerType) && reflect.PtrTo(t).Implements(scannerType))
+}
+
+// mergePatch applies the RFC 7396 patch on target.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := generic(target).(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+
+	return t
+}
+
+// applyOperation applies the RFC 6902 operation on doc.
+func applyOperation(doc map[string]interface{}, op patchOperation) error {
+	var value interface{}
+	if len(op.Value) > 0 {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return err
+		}
+	}
+
+	path, err := pointer(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add":
+		return patchSet(doc, path, value, true)
+	case "replace":
+		return patchSet(doc, path, value, false)
+	case "remove":
+		_, err = patchRemove(doc, path)
+		return err
+	case "move", "copy":
+		from, err := pointer(op.From)
+		if err != nil {
+			return err
+		}
+
+		if op.Op == "move" {
+			value, err = patchRemove(doc, from)
+		} else {
+			value, err = patchGet(doc, from)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return patchSet(doc, path, value, true)
+	case "test":
+		v, err := patchGet(doc, path)
+		if err != nil {
+			return err
+		}
+
+		if !reflect.DeepEqual(generic(v), generic(value)) {
+			return &ruleError{"patch_test", op.Path}
+		}
+
+		return nil
+	}
+
+	return &ruleError{"patch_op", op.Op}
+}
+
+// pointer splits the RFC 6901 json pointer into its unescaped tokens.
+func pointer(p string) ([]string, error) {
+	if !strings.HasPrefix(p, "/") {
+		return nil, &ruleError{"patch_path", p}
+	}
+
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+func rootKey(p string) string {
+	if t, err := pointer(p); err == nil {
+		return t[0]
+	}
+
+	return p
+}
+
+// patchChild returns the value at key of the container in its json form.
+func patchChild(c interface{}, key string) (interface{}, error) {
+	switch x := c.(type) {
+	case map[string]interface{}:
+		if v, ok := x[key]; ok {
+			return generic(v), nil
+		}
+	case []interface{}:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(x) {
+			return generic(x[i]), nil
+		}
+	}
+
+	return nil, &ruleError{"patch_found", key}
+}
+
+// patchUpdate walks the path and replaces the container of its last token
+// by the result of fn, returning c updated as slices can be reallocated.
+func patchUpdate(c interface{}, path []string, fn func(c interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(c, path[0])
+	}
+
+	child, err := patchChild(c, path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if child, err = patchUpdate(child, path[1:], fn); err != nil {
+		return nil, err
+	}
+
+	switch x := c.(type) {
+	case map[string]interface{}:
+		x[path[0]] = child
+	case []interface{}:
+		i, _ := strconv.Atoi(path[0])
+		x[i] = child
+	}
+
+	return c, nil
+}
+
+func patchGet(doc map[string]interface{}, path []string) (v interface{}, err error) {
+	var c interface{} = doc
+	for _, t := range path {
+		if c, err = patchChild(c, t); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func patchSet(doc map[string]interface{}, path []string, value interface{}, add bool) error {
+	_, err := patchUpdate(doc, path, func(c interface{}, key string) (interface{}, error) {
+		switch x := c.(type) {
+		case map[string]interface{}:
+			if _, ok := x[key]; !ok && !add {
+				return nil, &ruleError{"patch_found", key}
+			}
+
+			x[key] = value
+
+			return x, nil
+		case []interface{}:
+			i := len(x)
+			if key != "-" {
+				var err error
+				if i, err = strconv.Atoi(key); err != nil || i < 0 || i > len(x) || (!add && i == len(x)) {
+					return nil, &ruleError{"patch_found", key}
+				}
+			}
+
+			if add {
+				x = append(x, nil)
+				copy(x[i+1:], x[i:])
+			}
+
+			x[i] = value
+
+			return x, nil
+		}
+
+		return nil, &ruleError{"patch_found", key}
+	})
+
+	return err
+}
+
+func patchRemove(doc map[string]interface{}, path []string) (interface{}, error) {
+	v, err := patchGet(doc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = patchUpdate(doc, path, func(c interface{}, key string) (interface{}, error) {
+		switch x := c.(type) {
+		case map[string]interface{}:
+			delete(x, key)
+
+			return x, nil
+		case []interface{}:
+			i, _ := strconv.Atoi(key)
+
+			return append(x[:i], x[i+1:]...), nil
+		}
+
+		return c, nil
+	})
+
+	return v, err
+}
+
+// generic returns v as json decoded maps, slices and scalars.
+func generic(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, map[string]interface{}, []interface{}, string, float64, bool:
+		return v
+	}
+
+	var g interface{}
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &g)
+	}
+
+	return g
+}
@@ -0,0 +1,154 @@
+package cuxs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+func TestApplyOperation(t *testing.T) {
+	tests := []struct {
+		doc  string
+		op   patchOperation
+		want string
+		err  bool
+	}{
+		{`{"a":1}`, patchOperation{Op: "add", Path: "/b", Value: json.RawMessage(`2`)}, `{"a":1,"b":2}`, false},
+		{`{"a":[1,2]}`, patchOperation{Op: "add", Path: "/a/1", Value: json.RawMessage(`9`)}, `{"a":[1,9,2]}`, false},
+		{`{"a":[1,2]}`, patchOperation{Op: "add", Path: "/a/-", Value: json.RawMessage(`3`)}, `{"a":[1,2,3]}`, false},
+		{`{"a":[1,2]}`, patchOperation{Op: "add", Path: "/a/5", Value: json.RawMessage(`3`)}, ``, true},
+		{`{"a":1}`, patchOperation{Op: "replace", Path: "/a", Value: json.RawMessage(`"x"`)}, `{"a":"x"}`, false},
+		{`{"a":1}`, patchOperation{Op: "replace", Path: "/b", Value: json.RawMessage(`1`)}, ``, true},
+		{`{"a":{"b":1,"c":2}}`, patchOperation{Op: "remove", Path: "/a/b"}, `{"a":{"c":2}}`, false},
+		{`{"a":[1,2,3]}`, patchOperation{Op: "remove", Path: "/a/0"}, `{"a":[2,3]}`, false},
+		{`{"a":1}`, patchOperation{Op: "move", From: "/a", Path: "/b"}, `{"b":1}`, false},
+		{`{"a":{"x":1}}`, patchOperation{Op: "copy", From: "/a", Path: "/b"}, `{"a":{"x":1},"b":{"x":1}}`, false},
+		{`{"a":"x"}`, patchOperation{Op: "test", Path: "/a", Value: json.RawMessage(`"x"`)}, `{"a":"x"}`, false},
+		{`{"a":"x"}`, patchOperation{Op: "test", Path: "/a", Value: json.RawMessage(`"y"`)}, ``, true},
+		{`{"a/b":1}`, patchOperation{Op: "remove", Path: "/a~1b"}, `{}`, false},
+		{`{"a":1}`, patchOperation{Op: "add", Path: "a", Value: json.RawMessage(`1`)}, ``, true},
+		{`{"a":1}`, patchOperation{Op: "merge", Path: "/a"}, ``, true},
+	}
+
+	for _, tt := range tests {
+		var doc map[string]interface{}
+		json.Unmarshal([]byte(tt.doc), &doc)
+
+		err := applyOperation(doc, tt.op)
+		if (err != nil) != tt.err {
+			t.Errorf("%s %s on %s: error %v", tt.op.Op, tt.op.Path, tt.doc, err)
+			continue
+		}
+
+		if tt.err {
+			continue
+		}
+
+		var want map[string]interface{}
+		json.Unmarshal([]byte(tt.want), &want)
+		if !reflect.DeepEqual(doc, want) {
+			t.Errorf("%s %s on %s: got %v, want %v", tt.op.Op, tt.op.Path, tt.doc, doc, want)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[1]}`, `{"a":[2,3]}`, `{"a":[2,3]}`},
+		{`["a"]`, `{"a":"b"}`, `{"a":"b"}`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+	}
+
+	for _, tt := range tests {
+		var target, patch, want interface{}
+		json.Unmarshal([]byte(tt.target), &target)
+		json.Unmarshal([]byte(tt.patch), &patch)
+		json.Unmarshal([]byte(tt.want), &want)
+
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("merge %s into %s: got %v, want %v", tt.patch, tt.target, got, want)
+		}
+	}
+}
+
+type patchModel struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Note      *string    `json:"note"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+func TestUpdateModel(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&patchModel{})
+
+	tests := []struct {
+		body   string
+		errors []string
+		want   patchModel
+	}{
+		{`{"name":"b"}`, nil, patchModel{Id: 1, Name: "b"}},
+		{`{"note":null}`, nil, patchModel{Id: 1, Name: "a"}},
+		{`{"id":2}`, []string{"id"}, patchModel{Id: 1, Name: "a"}},
+		{`{"created_at":"2020-01-01T00:00:00Z"}`, []string{"created_at"}, patchModel{Id: 1, Name: "a"}},
+		{`{"name":null}`, []string{"name"}, patchModel{Id: 1, Name: "a"}},
+		{`{"other":1}`, []string{"other"}, patchModel{Id: 1, Name: "a"}},
+	}
+
+	for _, tt := range tests {
+		note := "n"
+		db.Unscoped().Delete(&patchModel{})
+		db.Create(&patchModel{Id: 1, Name: "a", Note: &note})
+
+		c, _ := newTestContext("PATCH", "/models/1", "application/json", tt.body)
+		h, err := NewHandler(c, &patchModel{})
+		if err != nil {
+			t.Fatalf("%s: prepare %v", tt.body, err)
+		}
+
+		model := new(patchModel)
+		db.First(model, 1)
+		err = h.UpdateModel(db, model)
+
+		var fields []string
+		for _, e := range h.Response.Errors {
+			fields = append(fields, e.Field)
+		}
+
+		if !reflect.DeepEqual(fields, tt.errors) {
+			t.Errorf("%s: errors %v, want %v", tt.body, fields, tt.errors)
+		}
+
+		if (err != nil) != (tt.errors != nil) {
+			t.Errorf("%s: error %v", tt.body, err)
+		}
+
+		saved := new(patchModel)
+		db.First(saved, 1)
+		if saved.Name != tt.want.Name || (tt.body == `{"note":null}`) != (saved.Note == nil) {
+			t.Errorf("%s: saved %+v", tt.body, saved)
+		}
+	}
+
+	db.Create(&patchModel{Id: 2, Name: "other"})
+	other := new(patchModel)
+	db.First(other, 2)
+	if other.Name != "other" {
+		t.Errorf("row 2 was changed to %+v", other)
+	}
+}
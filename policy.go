@@ -0,0 +1,196 @@
+package cuxs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/qasico/cuxs/middleware"
+	"github.com/qasico/cuxs/response"
+)
+
+type (
+	// Policy maps role names to their permissions.
+	Policy struct {
+		Roles map[string]Role `json:"roles"`
+
+		// ContextKey is the echo.Context key of the token claims, it must
+		// match the JWTConfig.ContextKey, default to "user".
+		ContextKey string `json:"context_key"`
+	}
+
+	// Role grants permissions and inherits the permissions of other roles.
+	Role struct {
+		Inherits    []string `json:"inherits"`
+		Permissions []string `json:"permissions"`
+	}
+)
+
+var (
+	policy   = &Policy{Roles: make(map[string]Role)}
+	policyMu sync.RWMutex
+)
+
+// LoadPolicy reads the roles policy from a json file such as
+//
+//	{"roles": {"admin": {"inherits": ["staff"], "permissions": ["*"]},
+//	           "staff": {"permissions": ["orders:read", "orders:write"]}}}
+func LoadPolicy(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	p := &Policy{}
+	if err = json.Unmarshal(b, p); err != nil {
+		return err
+	}
+
+	SetPolicy(p)
+
+	return nil
+}
+
+// SetPolicy replaces the roles policy used by Require and RequireRole.
+func SetPolicy(p *Policy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+
+	policy = p
+}
+
+// Require returns a middleware that allows the request only when the
+// token claims grant every permission, either directly in the
+// "permissions" claim or through the roles in the "roles" claim.
+func Require(permissions ...string) echo.MiddlewareFunc {
+	return guard(func(claims jwt.MapClaims) bool {
+		granted := grantedPermissions(claims)
+		for _, p := range permissions {
+			if !allowed(granted, p) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// RequireRole returns a middleware that allows the request only when the
+// token claims have one of the roles, or a role inheriting from it.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return guard(func(claims jwt.MapClaims) bool {
+		policyMu.RLock()
+		defer policyMu.RUnlock()
+
+		for _, r := range claimStrings(claims, "roles") {
+			for _, e := range policy.expand(r) {
+				for _, role := range roles {
+					if e == role {
+						return true
+					}
+				}
+			}
+		}
+
+		return false
+	})
+}
+
+func guard(check func(claims jwt.MapClaims) bool) echo.MiddlewareFunc {
+	return func(n echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			policyMu.RLock()
+			key := policy.ContextKey
+			policyMu.RUnlock()
+
+			if key == "" {
+				key = middleware.DefaultJWTContextKey
+			}
+
+			claims := middleware.ClaimsOf(c, key)
+			if claims == nil {
+				return deny(c, response.StatusUnauthorized)
+			}
+
+			if !check(claims) {
+				return deny(c, response.StatusForbidden)
+			}
+
+			return n(c)
+		}
+	}
+}
+
+func deny(c echo.Context, code int) error {
+	r := response.Attribute{Code: code, Status: response.StatusFailed, Message: response.StatusText(code)}
+
+	return response.Render(c, code, r)
+}
+
+// expand returns the role with every role it inherits, must be called with the lock held.
+func (p *Policy) expand(role string) (roles []string) {
+	seen := map[string]bool{}
+	queue := []string{role}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		if seen[r] {
+			continue
+		}
+
+		seen[r] = true
+		roles = append(roles, r)
+		queue = append(queue, p.Roles[r].Inherits...)
+	}
+
+	return
+}
+
+func grantedPermissions(claims jwt.MapClaims) []string {
+	granted := claimStrings(claims, "permissions")
+
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+
+	for _, r := range claimStrings(claims, "roles") {
+		for _, e := range policy.expand(r) {
+			granted = append(granted, policy.Roles[e].Permissions...)
+		}
+	}
+
+	return granted
+}
+
+// allowed checks the permission against the granted ones,
+// supporting "*" and "resource:*" wildcards.
+func allowed(granted []string, permission string) bool {
+	for _, g := range granted {
+		if g == "*" || g == permission {
+			return true
+		}
+
+		if strings.HasSuffix(g, ":*") && strings.HasPrefix(permission, g[:len(g)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func claimStrings(claims jwt.MapClaims, key string) (values []string) {
+	switch v := claims[key].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []interface{}:
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,74 @@
+package cuxs
+
+import (
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+func TestPolicyGuard(t *testing.T) {
+	defer SetPolicy(policy)
+
+	SetPolicy(&Policy{Roles: map[string]Role{
+		"admin":   {Inherits: []string{"manager"}, Permissions: []string{"*"}},
+		"manager": {Inherits: []string{"staff"}, Permissions: []string{"orders:*"}},
+		"staff":   {Permissions: []string{"orders:read"}},
+	}})
+
+	tests := []struct {
+		name   string
+		guard  echo.MiddlewareFunc
+		claims jwt.MapClaims
+		code   int
+	}{
+		{"no claims", Require("orders:read"), nil, 401},
+		{"direct permission", Require("orders:read"), jwt.MapClaims{"permissions": "orders:read"}, 200},
+		{"missing permission", Require("orders:write"), jwt.MapClaims{"permissions": "orders:read"}, 403},
+		{"role permission", Require("orders:read"), jwt.MapClaims{"roles": []interface{}{"staff"}}, 200},
+		{"resource wildcard", Require("orders:write"), jwt.MapClaims{"roles": "manager"}, 200},
+		{"resource wildcard scope", Require("users:write"), jwt.MapClaims{"roles": "manager"}, 403},
+		{"wildcard", Require("users:write", "orders:read"), jwt.MapClaims{"roles": "admin"}, 200},
+		{"every permission", Require("orders:read", "orders:write"), jwt.MapClaims{"roles": "staff"}, 403},
+		{"role", RequireRole("staff"), jwt.MapClaims{"roles": "staff"}, 200},
+		{"inherited role", RequireRole("staff"), jwt.MapClaims{"roles": "admin"}, 200},
+		{"not inherited", RequireRole("admin"), jwt.MapClaims{"roles": "manager"}, 403},
+		{"role without claims", RequireRole("staff"), nil, 401},
+	}
+
+	for _, tt := range tests {
+		c, rec := newTestContext("GET", "/orders", "", "")
+		if tt.claims != nil {
+			c.Set("user", tt.claims)
+		}
+
+		tt.guard(func(c echo.Context) error { return c.NoContent(200) })(c)
+		if rec.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.code)
+		}
+	}
+}
+
+func TestPolicyContextKey(t *testing.T) {
+	defer SetPolicy(policy)
+
+	SetPolicy(&Policy{Roles: map[string]Role{"staff": {Permissions: []string{"orders:read"}}}, ContextKey: "claims"})
+
+	tests := []struct {
+		key  string
+		code int
+	}{
+		{"claims", 200},
+		{"user", 401},
+	}
+
+	for _, tt := range tests {
+		c, rec := newTestContext("GET", "/orders", "", "")
+		c.Set(tt.key, jwt.MapClaims{"roles": "staff"})
+
+		Require("orders:read")(func(c echo.Context) error { return c.NoContent(200) })(c)
+		if rec.Code != tt.code {
+			t.Errorf("claims under %s: got %d, want %d", tt.key, rec.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,708 @@
+package cuxs
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	cerrors "github.com/qasico/cuxs/errors"
+	"github.com/qasico/cuxs/idcodec"
+	"github.com/qasico/cuxs/response"
+)
+
+type (
+	// ResourceOptions configures the routes registered by Resource.
+	ResourceOptions struct {
+		// DB returns the database of the resource, default to DBOf the request.
+		DB func() *gorm.DB
+
+		// Request is the struct bound and validated on create and update,
+		// default to the model itself.
+		Request interface{}
+
+		// Filters and Sorts whitelist the list query,
+		// nothing is filterable or sortable when unset.
+		Filters FilterRules
+		Sorts   SortRules
+
+		// Search lists the json fields matched by the q search term,
+		// the search is rejected when unset.
+		Search []string
+
+		// Only limits the registered actions among
+		// list, show, create, update and delete.
+		Only []string
+
+		// OpaqueID decodes the :id path param with the id codec.
+		OpaqueID bool
+
+		// HardDelete deletes the rows even when the model has DeletedAt.
+		HardDelete bool
+
+		// Bulk registers create, update and delete of json arrays on
+		// path/bulk. A failed item rolls back the whole bulk, unless
+		// BulkPartial is set or the client asks for mode=partial.
+		Bulk        bool
+		BulkPartial bool
+
+		Middleware []echo.MiddlewareFunc
+		Hooks      ResourceHooks
+	}
+
+	// ResourceHooks are called around the resource actions, the create,
+	// update and delete hooks run inside the transaction of the action.
+	// An error returned by a hook stops the action.
+	ResourceHooks struct {
+		BeforeList   func(h *Handler, db *gorm.DB) (*gorm.DB, error)
+		AfterList    func(h *Handler, items interface{}) error
+		AfterShow    func(h *Handler, model interface{}) error
+		BeforeCreate func(h *Handler, tx *gorm.DB, model interface{}) error
+		AfterCreate  func(h *Handler, tx *gorm.DB, model interface{}) error
+		BeforeUpdate func(h *Handler, tx *gorm.DB, model interface{}) error
+		AfterUpdate  func(h *Handler, tx *gorm.DB, model interface{}) error
+		BeforeDelete func(h *Handler, tx *gorm.DB, model interface{}) error
+		AfterDelete  func(h *Handler, tx *gorm.DB, model interface{}) error
+	}
+
+	resource struct {
+		typ  reflect.Type
+		opts ResourceOptions
+	}
+)
+
+// Resource registers the list, show, create, update and delete routes of
+// the model on path, PUT replaces the model and PATCH changes the fields
+// present in the body. For example
+//
+//	cuxs.Resource(g, "/orders", &Order{}, cuxs.ResourceOptions{Request: &OrderRequest{}})
+func Resource(g *echo.Group, path string, model interface{}, opts ResourceOptions) {
+	r := &resource{typ: reflect.TypeOf(model).Elem(), opts: opts}
+
+	if r.has("list") {
+		r.add(g, echo.GET, path, r.list)
+	}
+
+	if r.has("show") {
+		r.add(g, echo.GET, path+"/:id", r.show)
+	}
+
+	if r.has("create") {
+		r.add(g, echo.POST, path, r.create)
+	}
+
+	if r.has("update") {
+		r.add(g, echo.PUT, path+"/:id", r.replace)
+		r.add(g, echo.PATCH, path+"/:id", r.update)
+	}
+
+	if r.has("delete") {
+		r.add(g, echo.DELETE, path+"/:id", r.delete)
+	}
+
+	if opts.Bulk {
+		if r.has("create") {
+			r.add(g, echo.POST, path+"/bulk", r.bulkCreate)
+		}
+
+		if r.has("update") {
+			r.add(g, echo.PUT, path+"/bulk", r.bulkUpdate)
+		}
+
+		if r.has("delete") {
+			r.add(g, echo.DELETE, path+"/bulk", r.bulkDelete)
+		}
+	}
+}
+
+// add registers the route, marked transactional when the resource runs on DBOf.
+func (r *resource) add(g *echo.Group, method string, path string, h echo.HandlerFunc) {
+	g.Match([]string{method}, path, h, r.opts.Middleware...)
+
+	if r.opts.DB == nil {
+		Transactional(g, method, path)
+	}
+}
+
+func (r *resource) has(action string) bool {
+	return len(r.opts.Only) == 0 || inStrings(r.opts.Only, action)
+}
+
+func (r *resource) db(h *Handler) *gorm.DB {
+	if r.opts.DB != nil {
+		return r.opts.DB()
+	}
+
+	return DBOf(h.Context)
+}
+
+func (r *resource) new() interface{} {
+	return reflect.New(r.typ).Interface()
+}
+
+// requestType returns the type of the request struct.
+func (r *resource) requestType() reflect.Type {
+	if r.opts.Request == nil {
+		return r.typ
+	}
+
+	return reflect.TypeOf(r.opts.Request).Elem()
+}
+
+// request returns a new request struct, or nil when the model is bound.
+func (r *resource) request() interface{} {
+	if r.opts.Request == nil {
+		return nil
+	}
+
+	return reflect.New(reflect.TypeOf(r.opts.Request).Elem()).Interface()
+}
+
+func (r *resource) list(c echo.Context) error {
+	h, err := NewHandler(c, nil)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	model := r.new()
+	db := r.db(h)
+
+	h.AllowFilters(r.opts.Filters)
+	h.AllowSorts(r.opts.Sorts)
+	h.AllowSearch(r.opts.Search)
+	r.allowEmbeds(h, newModelSchema(db, model))
+	if len(h.Response.Errors) > 0 {
+		return h.Respond(errValidation)
+	}
+
+	if r.opts.Hooks.BeforeList != nil {
+		if db, err = r.opts.Hooks.BeforeList(h, db); err != nil {
+			return h.Respond(err)
+		}
+	}
+
+	items := reflect.New(reflect.SliceOf(reflect.PtrTo(r.typ)))
+	if err = h.QueryParam.Apply(db, model).Find(items.Interface()).Error; err != nil {
+		if h.paramErrors(err) {
+			return h.Respond(errValidation)
+		}
+
+		return h.Respond(dbError(err))
+	}
+
+	if err = h.QueryParam.SetCursors(items.Interface()); err != nil {
+		return h.Respond(cerrors.Internal(err))
+	}
+
+	if r.opts.Hooks.AfterList != nil {
+		if err = r.opts.Hooks.AfterList(h, items.Interface()); err != nil {
+			return h.Respond(err)
+		}
+	}
+
+	h.Response.SetData(items.Elem().Interface())
+
+	return h.Respond(nil)
+}
+
+func (r *resource) show(c echo.Context) error {
+	h, err := NewHandler(c, nil)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	model := r.new()
+	if !r.allowEmbeds(h, newModelSchema(r.db(h), model)) {
+		return h.Respond(errValidation)
+	}
+
+	db := h.QueryParam.Preload(r.db(h), model)
+	if err = r.load(h, db, model); err != nil {
+		return h.Respond(err)
+	}
+
+	if r.opts.Hooks.AfterShow != nil {
+		if err = r.opts.Hooks.AfterShow(h, model); err != nil {
+			return h.Respond(err)
+		}
+	}
+
+	h.Response.SetData(model)
+
+	return h.Respond(nil)
+}
+
+func (r *resource) create(c echo.Context) error {
+	model := r.new()
+	req := r.request()
+	if req == nil {
+		req = model
+	}
+
+	h, err := (&Handler{DB: r.ownDB()}).Prepare(c, req)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	if req != model {
+		if err = copyRequest(req, model); err != nil {
+			return h.Respond(cerrors.Internal(err))
+		}
+	}
+
+	err = r.transaction(h, model, r.opts.Hooks.BeforeCreate, r.opts.Hooks.AfterCreate, func(tx *gorm.DB) error {
+		clearReadonly(tx, model)
+
+		return tx.Create(model).Error
+	})
+
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	h.SetCreated(model)
+
+	return h.Respond(nil)
+}
+
+// update changes the fields present in the body, validating only them.
+func (r *resource) update(c echo.Context) error {
+	model := r.new()
+	req := r.request()
+	if req == nil {
+		req = r.new()
+	}
+
+	h, err := (&Handler{Partial: true, DB: r.ownDB(), RowID: r.rowID(c)}).Prepare(c, req)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	if err = r.load(h, r.db(h), model); err != nil {
+		return h.Respond(err)
+	}
+
+	err = r.transaction(h, model, r.opts.Hooks.BeforeUpdate, r.opts.Hooks.AfterUpdate, func(tx *gorm.DB) error {
+		return h.UpdateModel(tx, model)
+	})
+
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	h.Response.SetData(model)
+
+	return h.Respond(nil)
+}
+
+// replace overwrites the model with the request, keeping its
+// primary key and timestamps.
+func (r *resource) replace(c echo.Context) error {
+	model := r.new()
+	req := r.request()
+	if req == nil {
+		req = r.new()
+	}
+
+	h, err := (&Handler{DB: r.ownDB(), RowID: r.rowID(c)}).Prepare(c, req)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	if err = r.load(h, r.db(h), model); err != nil {
+		return h.Respond(err)
+	}
+
+	err = r.transaction(h, model, r.opts.Hooks.BeforeUpdate, r.opts.Hooks.AfterUpdate, func(tx *gorm.DB) error {
+		if err := replaceModel(tx, req, model); err != nil {
+			return cerrors.Internal(err)
+		}
+
+		return tx.Save(model).Error
+	})
+
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	h.Response.SetData(model)
+
+	return h.Respond(nil)
+}
+
+func (r *resource) delete(c echo.Context) error {
+	h, err := NewHandler(c, nil)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	model := r.new()
+	if err = r.load(h, r.db(h), model); err != nil {
+		return h.Respond(err)
+	}
+
+	err = r.transaction(h, model, r.opts.Hooks.BeforeDelete, r.opts.Hooks.AfterDelete, func(tx *gorm.DB) error {
+		if r.opts.HardDelete {
+			tx = tx.Unscoped()
+		}
+
+		return tx.Delete(model).Error
+	})
+
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	h.Response.SetData(model)
+
+	return h.Respond(nil)
+}
+
+// allowEmbeds checks the embed query string against the model relations,
+// unknown embeds are added as validation errors on the response.
+func (r *resource) allowEmbeds(h *Handler, s *modelSchema) (valid bool) {
+	valid = true
+	for _, e := range h.QueryParam.Embed {
+		if _, ok := s.relations[e]; !ok {
+			h.setParamError("embed", "unknown_field", e)
+			valid = false
+		}
+	}
+
+	return
+}
+
+// load finds the model of the :id path param.
+func (r *resource) load(h *Handler, db *gorm.DB, model interface{}) error {
+	return r.loadID(db, model, h.Context.Param("id"))
+}
+
+// loadID finds the model of id.
+func (r *resource) loadID(db *gorm.DB, model interface{}, id string) error {
+	id, err := r.decodeID(id)
+	if err != nil {
+		return dbError(gorm.ErrRecordNotFound)
+	}
+
+	s := newModelSchema(db, model)
+
+	return dbError(db.Where(s.scope.Quote(s.scope.PrimaryKey())+" = ?", id).First(model).Error)
+}
+
+// decodeID returns the primary key of the id sent by the client.
+func (r *resource) decodeID(id string) (string, error) {
+	if !r.opts.OpaqueID {
+		return id, nil
+	}
+
+	n, err := idcodec.Decode(id)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(n, 10), nil
+}
+
+// rowID returns the primary key of the :id path param,
+// excluded from the unique rule of the request.
+func (r *resource) rowID(c echo.Context) interface{} {
+	id, err := r.decodeID(c.Param("id"))
+	if err != nil {
+		return nil
+	}
+
+	return id
+}
+
+// transaction runs the action between its hooks in a database transaction.
+func (r *resource) transaction(h *Handler, model interface{}, before, after func(*Handler, *gorm.DB, interface{}) error, action func(tx *gorm.DB) error) (err error) {
+	// a transactional batch commits or rolls back the whole batch
+	if tx, ok := batchTx(h.Context); ok {
+		if r.opts.DB != nil {
+			return errBatchTransaction
+		}
+
+		return r.run(h, tx, model, before, after, action)
+	}
+
+	tx := r.db(h).Begin()
+	if tx.Error != nil {
+		return dbError(tx.Error)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+
+		if err != nil {
+			tx.Rollback()
+		} else if err = tx.Commit().Error; err != nil {
+			err = dbError(err)
+		}
+	}()
+
+	return r.run(h, tx, model, before, after, action)
+}
+
+func (r *resource) run(h *Handler, tx *gorm.DB, model interface{}, before, after func(*Handler, *gorm.DB, interface{}) error, action func(tx *gorm.DB) error) error {
+	if before != nil {
+		if err := before(h, tx, model); err != nil {
+			return err
+		}
+	}
+
+	if err := action(tx); err != nil {
+		if _, ok := cerrors.As(err); !ok && err != errValidation {
+			err = dbError(err)
+		}
+
+		return err
+	}
+
+	if after != nil {
+		return after(h, tx, model)
+	}
+
+	return nil
+}
+
+// copyRequest copies the request struct fields to the model by their json names.
+func copyRequest(req interface{}, model interface{}) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, model)
+}
+
+// replaceModel copies the request to the model, keeping the
+// primary key and the timestamps gorm maintains.
+func replaceModel(db *gorm.DB, req interface{}, model interface{}) error {
+	s := newModelSchema(db, model)
+	mv := reflect.Indirect(reflect.ValueOf(model))
+
+	kept := map[string]interface{}{}
+	for _, column := range []string{s.scope.PrimaryKey(), "created_at", "deleted_at"} {
+		if name, ok := s.fields[column]; ok {
+			kept[name] = mv.FieldByName(name).Interface()
+		}
+	}
+
+	if err := copyRequest(req, model); err != nil {
+		return err
+	}
+
+	for name, v := range kept {
+		mv.FieldByName(name).Set(reflect.ValueOf(v))
+	}
+
+	return nil
+}
+
+// clearReadonly zeroes the primary key and the gorm timestamps
+// of a created model, the client cannot set them.
+func clearReadonly(db *gorm.DB, model interface{}) {
+	s := newModelSchema(db, model)
+	mv := reflect.Indirect(reflect.ValueOf(model))
+	for column, name := range s.fields {
+		if column == s.scope.PrimaryKey() || readonlyColumns[column] {
+			f := mv.FieldByName(name)
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+}
+
+// dbError hides database errors behind application errors.
+func dbError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case gorm.ErrRecordNotFound:
+		return cerrors.NotFound("not_found", response.StatusText(response.StatusNotFound))
+	}
+
+	if e, ok := cerrors.As(err); ok {
+		return e
+	}
+
+	if isConstraintError(err) {
+		return cerrors.Conflict("constraint", "Conflicts with the existing data").WithCause(err)
+	}
+
+	return cerrors.Internal(err)
+}
+
+// isConstraintError reports whether err is a unique, foreign key, not null
+// or check violation, the drivers only tell them apart by their messages.
+func isConstraintError(err error) bool {
+	m := strings.ToLower(err.Error())
+	for _, s := range []string{"constraint", "duplicate entry", "duplicate key"} {
+		if strings.Contains(m, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ownDB returns the database of the options, nil for the request database.
+func (r *resource) ownDB() *gorm.DB {
+	if r.opts.DB != nil {
+		return r.opts.DB()
+	}
+
+	return nil
+}
+
+// bindBulk binds the items of a bulk request in the mode of the mode query string.
+func (r *resource) bindBulk(h *Handler, items interface{}) (*Bulk, error) {
+	partial := r.opts.BulkPartial
+	switch h.Context.QueryParam("mode") {
+	case "partial":
+		partial = true
+	case "atomic":
+		partial = false
+	}
+
+	b, err := h.BindBulk(items, partial)
+	if err == errValidation && partial {
+		err = nil
+	}
+
+	return b, err
+}
+
+func (r *resource) bulkCreate(c echo.Context) error {
+	h, err := (&Handler{DB: r.ownDB()}).Prepare(c, nil)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	reqs := reflect.New(reflect.SliceOf(reflect.PtrTo(r.requestType())))
+	b, err := r.bindBulk(h, reqs.Interface())
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	models := make([]interface{}, b.Len())
+	err = b.Run(r.ownDB(), func(tx *gorm.DB, i int) error {
+		model := reqs.Elem().Index(i).Interface()
+		if r.opts.Request != nil {
+			model = r.new()
+			if err := copyRequest(reqs.Elem().Index(i).Interface(), model); err != nil {
+				return cerrors.Internal(err)
+			}
+		}
+
+		models[i] = model
+
+		return r.run(h, tx, model, r.opts.Hooks.BeforeCreate, r.opts.Hooks.AfterCreate, func(tx *gorm.DB) error {
+			clearReadonly(tx, model)
+
+			return tx.Create(model).Error
+		})
+	})
+
+	return b.Respond(models, err)
+}
+
+// bulkUpdate replaces the models of the id of every item.
+func (r *resource) bulkUpdate(c echo.Context) error {
+	h, err := (&Handler{DB: r.ownDB()}).Prepare(c, nil)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	reqs := reflect.New(reflect.SliceOf(reflect.PtrTo(r.requestType())))
+	b, err := r.bindBulk(h, reqs.Interface())
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	models := make([]interface{}, b.Len())
+	err = b.Run(r.ownDB(), func(tx *gorm.DB, i int) error {
+		var keys map[string]json.RawMessage
+		json.Unmarshal(b.Raw(i), &keys)
+
+		id := rawID(keys["id"])
+		if id == "" {
+			h.setRuleError("id", "required", "", reflect.Invalid)
+			return errValidation
+		}
+
+		model := r.new()
+		if err := r.loadID(tx, model, id); err != nil {
+			return err
+		}
+
+		models[i] = model
+
+		return r.run(h, tx, model, r.opts.Hooks.BeforeUpdate, r.opts.Hooks.AfterUpdate, func(tx *gorm.DB) error {
+			if err := replaceModel(tx, reqs.Elem().Index(i).Interface(), model); err != nil {
+				return cerrors.Internal(err)
+			}
+
+			return tx.Save(model).Error
+		})
+	})
+
+	return b.Respond(models, err)
+}
+
+// bulkDelete deletes the models of a json array of ids.
+func (r *resource) bulkDelete(c echo.Context) error {
+	h, err := (&Handler{DB: r.ownDB()}).Prepare(c, nil)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	var ids []json.RawMessage
+	b, err := r.bindBulk(h, &ids)
+	if err != nil {
+		return h.Respond(err)
+	}
+
+	models := make([]interface{}, b.Len())
+	err = b.Run(r.ownDB(), func(tx *gorm.DB, i int) error {
+		model := r.new()
+		if err := r.loadID(tx, model, rawID(ids[i])); err != nil {
+			return err
+		}
+
+		models[i] = model
+
+		return r.run(h, tx, model, r.opts.Hooks.BeforeDelete, r.opts.Hooks.AfterDelete, func(tx *gorm.DB) error {
+			if r.opts.HardDelete {
+				tx = tx.Unscoped()
+			}
+
+			return tx.Delete(model).Error
+		})
+	})
+
+	return b.Respond(models, err)
+}
+
+// rawID returns the json number or string id as a string.
+func rawID(raw json.RawMessage) string {
+	// keep numbers as json.Number so large ids are not rounded
+	var id interface{}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	d.Decode(&id)
+
+	switch v := id.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+
+	return ""
+}
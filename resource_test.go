@@ -0,0 +1,241 @@
+package cuxs
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
+)
+
+type resourceModel struct {
+	Id           int64  `json:"id"`
+	Name         string `json:"name" validate:"required"`
+	Email        string `json:"email" validate:"required,email"`
+	PasswordHash string `json:"-"`
+}
+
+func newTestResource(t *testing.T, opts ResourceOptions) (*echo.Echo, *gorm.DB) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.AutoMigrate(&resourceModel{})
+	db.Create(&resourceModel{Id: 1, Name: "a", Email: "a@x.com", PasswordHash: "h"})
+
+	opts.DB = func() *gorm.DB { return db }
+
+	e := echo.New()
+	Resource(e.Group(""), "/models", &resourceModel{}, opts)
+
+	return e, db
+}
+
+func serveTest(e *echo.Echo, method string, target string, body string) (int, map[string]interface{}) {
+	return serveTestType(e, method, target, echo.MIMEApplicationJSON, body)
+}
+
+func serveTestType(e *echo.Echo, method string, target string, contentType string, body string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(standard.NewRequest(req, e.Logger()), standard.NewResponse(rec, e.Logger()))
+
+	var res map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+
+	return rec.Code, res
+}
+
+func TestResourceUpdate(t *testing.T) {
+	e, db := newTestResource(t, ResourceOptions{})
+
+	tests := []struct {
+		method, body string
+		code         int
+		want         resourceModel
+	}{
+		{"PATCH", `{"name":"b"}`, 200, resourceModel{Id: 1, Name: "b", Email: "a@x.com", PasswordHash: "h"}},
+		{"PATCH", `[{"op":"replace","path":"/name","value":"d"}]`, 200, resourceModel{Id: 1, Name: "d", Email: "a@x.com", PasswordHash: "h"}},
+		{"PATCH", `[{"op":"replace","path":"/email","value":"not-an-email"},{"op":"replace","path":"/name","value":""}]`, 422, resourceModel{Id: 1, Name: "a", Email: "a@x.com", PasswordHash: "h"}},
+		{"PATCH", `{"email":"bad"}`, 422, resourceModel{Id: 1, Name: "a", Email: "a@x.com", PasswordHash: "h"}},
+		{"PUT", `{"name":"c"}`, 422, resourceModel{Id: 1, Name: "a", Email: "a@x.com", PasswordHash: "h"}},
+		{"PUT", `{"id":7,"name":"c","email":"c@x.com"}`, 200, resourceModel{Id: 1, Name: "c", Email: "c@x.com", PasswordHash: "h"}},
+	}
+
+	for _, tt := range tests {
+		db.Model(&resourceModel{Id: 1}).Updates(map[string]interface{}{"name": "a", "email": "a@x.com"})
+
+		ct := echo.MIMEApplicationJSON
+		if strings.HasPrefix(tt.body, "[") {
+			ct = MIMEJSONPatch
+		}
+
+		if code, res := serveTestType(e, tt.method, "/models/1", ct, tt.body); code != tt.code {
+			t.Errorf("%s %s: got %d %v, want %d", tt.method, tt.body, code, res, tt.code)
+		}
+
+		got := resourceModel{}
+		db.First(&got, 1)
+		if got != tt.want {
+			t.Errorf("%s %s: saved %+v, want %+v", tt.method, tt.body, got, tt.want)
+		}
+	}
+
+	var n int
+	if db.Model(&resourceModel{}).Count(&n); n != 1 {
+		t.Errorf("%d rows, want 1", n)
+	}
+}
+
+func TestResourceCreate(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&patchModel{})
+
+	e := echo.New()
+	Resource(e.Group(""), "/models", &patchModel{}, ResourceOptions{DB: func() *gorm.DB { return db }, Bulk: true})
+
+	tests := []struct {
+		target, body string
+		code         int
+	}{
+		{"/models", `{"id":99,"name":"a","created_at":"2001-01-01T00:00:00Z","deleted_at":"2001-01-01T00:00:00Z"}`, 201},
+		{"/models/bulk", `[{"id":98,"name":"b","deleted_at":"2001-01-01T00:00:00Z"}]`, 200},
+	}
+
+	for _, tt := range tests {
+		if code, res := serveTest(e, "POST", tt.target, tt.body); code != tt.code {
+			t.Errorf("%s %s: got %d %v, want %d", tt.target, tt.body, code, res, tt.code)
+		}
+	}
+
+	var rows []patchModel
+	db.Unscoped().Order("id").Find(&rows)
+	if len(rows) != 2 {
+		t.Fatalf("saved %+v, want 2 rows", rows)
+	}
+
+	for i, m := range rows {
+		if m.Id != int64(i+1) || m.DeletedAt != nil || m.CreatedAt.Year() == 2001 {
+			t.Errorf("saved %+v, the client set a readonly field", m)
+		}
+	}
+}
+
+type uniqueModel struct {
+	Id    int64  `json:"id"`
+	Email string `json:"email" validate:"required,unique=unique_models.email"`
+}
+
+func TestResourceUnique(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&uniqueModel{})
+	db.Create(&uniqueModel{Id: 1, Email: "a@x.com"})
+	db.Create(&uniqueModel{Id: 2, Email: "b@x.com"})
+
+	e := echo.New()
+	Resource(e.Group(""), "/models", &uniqueModel{}, ResourceOptions{DB: func() *gorm.DB { return db }})
+
+	tests := []struct {
+		method, target, body string
+		code                 int
+	}{
+		{"PUT", "/models/1", `{"email":"a@x.com"}`, 200},
+		{"PATCH", "/models/1", `{"email":"a@x.com"}`, 200},
+		{"PATCH", "/models/1", `{"email":"b@x.com"}`, 422},
+		{"PUT", "/models/1", `{"email":"b@x.com"}`, 422},
+		{"POST", "/models", `{"email":"b@x.com"}`, 422},
+		{"POST", "/models", `{"email":"c@x.com"}`, 201},
+	}
+
+	for _, tt := range tests {
+		if code, res := serveTest(e, tt.method, tt.target, tt.body); code != tt.code {
+			t.Errorf("%s %s %s: got %d %v, want %d", tt.method, tt.target, tt.body, code, res, tt.code)
+		}
+	}
+}
+
+func TestRawID(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{`1`, "1"},
+		{`9007199254740993`, "9007199254740993"},
+		{`"jR3k"`, "jR3k"},
+		{`null`, ""},
+		{`{"id":1}`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := rawID(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestResourceQuery(t *testing.T) {
+	e, _ := newTestResource(t, ResourceOptions{Filters: FilterRules{"name": nil}, Sorts: SortRules{"name": "name"}})
+
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/models?filter[name]=a&sort=-name", 200},
+		{"/models?filter[email]=a@x.com", 422},
+		{"/models?filter[password_hash][like]=h%25", 422},
+		{"/models?sort=email", 422},
+		{"/models?embed=owner", 422},
+		{"/models/1", 200},
+		{"/models/1?embed=owner", 422},
+		{"/models/2", 404},
+	}
+
+	for _, tt := range tests {
+		if code, res := serveTest(e, "GET", tt.target, ""); code != tt.code {
+			t.Errorf("%s: got %d %v, want %d", tt.target, code, res, tt.code)
+		}
+	}
+
+	// nothing is filterable without rules
+	e, _ = newTestResource(t, ResourceOptions{})
+	if code, _ := serveTest(e, "GET", "/models?filter[name]=a", ""); code != 422 {
+		t.Errorf("unlisted filter: got %d, want 422", code)
+	}
+}
+
+func TestResourceUnknownParam(t *testing.T) {
+	// rules naming fields the model does not have are only caught by Apply
+	e, _ := newTestResource(t, ResourceOptions{Filters: FilterRules{"nope": nil}, Sorts: SortRules{"nope": "nope"}, Search: []string{"nope"}})
+
+	tests := []struct {
+		target string
+		field  string
+	}{
+		{"/models?filter[nope]=a", "filter[nope][eq]"},
+		{"/models?sort=-nope", "sort"},
+		{"/models?cursor=&sort=nope", "sort"},
+		{"/models?q=a", "q"},
+	}
+
+	for _, tt := range tests {
+		code, res := serveTest(e, "GET", tt.target, "")
+		errs, _ := res["errors"].([]interface{})
+		if code != 422 || len(errs) != 1 || errs[0].(map[string]interface{})["field"] != tt.field {
+			t.Errorf("%s: got %d %v, want 422 on %s", tt.target, code, res, tt.field)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type (
+	// Problem is the RFC 7807 problem details document.
+	Problem struct {
+		Type          string         `json:"type"`
+		Title         string         `json:"title"`
+		Status        int            `json:"status"`
+		Detail        string         `json:"detail,omitempty"`
+		Instance      string         `json:"instance,omitempty"`
+		Code          string         `json:"code,omitempty"`
+		Cause         string         `json:"cause,omitempty"`
+		InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+	}
+
+	// InvalidParam is an entry of the invalid-params extension.
+	InvalidParam struct {
+		Name   string `json:"name"`
+		Reason string `json:"reason"`
+	}
+)
+
+const (
+	ErrorFormatDefault = "default"
+	ErrorFormatProblem = "problem"
+
+	MIMEProblemJSON = "application/problem+json"
+	MIMEProblemXML  = "application/problem+xml"
+)
+
+var (
+	// ErrorFormat selects how failed responses are rendered,
+	// the Attribute envelope by default or RFC 7807 problem documents.
+	ErrorFormat = ErrorFormatDefault
+
+	// ProblemTypeURI is the base of the problem type uris,
+	// when empty the type is about:blank.
+	ProblemTypeURI = ""
+)
+
+// NewProblem converts a failed response to a problem document.
+func NewProblem(r *Attribute, instance string) *Problem {
+	p := &Problem{Status: r.Code, Title: StatusText(r.Code), Instance: instance, Code: r.ErrorCode, Cause: r.Cause}
+	if p.Title == "" {
+		p.Title = http.StatusText(r.Code)
+	}
+
+	p.Type = "about:blank"
+	if ProblemTypeURI != "" {
+		p.Type = strings.TrimSuffix(ProblemTypeURI, "/") + "/" + strings.ToLower(strings.Replace(p.Title, " ", "-", -1))
+	}
+
+	if r.Message != nil {
+		if d := fmt.Sprint(r.Message); d != p.Title {
+			p.Detail = d
+		}
+	}
+
+	for _, e := range r.Errors {
+		p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: e.Field, Reason: e.Message})
+	}
+
+	return p
+}
+
+// problemOf returns the problem document of v when problem
+// errors are enabled and v is a failed response.
+func problemOf(v interface{}, instance string) (*Problem, bool) {
+	if ErrorFormat != ErrorFormatProblem {
+		return nil, false
+	}
+
+	var r *Attribute
+	switch a := v.(type) {
+	case *Attribute:
+		r = a
+	case Attribute:
+		r = &a
+	}
+
+	if r == nil || r.Status != StatusFailed {
+		return nil, false
+	}
+
+	return NewProblem(r, instance), true
+}
@@ -0,0 +1,62 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
+)
+
+func TestRenderProblem(t *testing.T) {
+	defer func(f, uri string) { ErrorFormat, ProblemTypeURI = f, uri }(ErrorFormat, ProblemTypeURI)
+
+	invalid := Attribute{Code: 422, Status: StatusFailed, Message: StatusText(422), Errors: []ErrorValidation{{Field: "name", Message: "is required", Rule: "required"}}}
+	notFound := Attribute{Code: 404, Status: StatusFailed, Message: "Order not found", ErrorCode: "order_not_found"}
+
+	tests := []struct {
+		format, typeURI string
+		accept          string
+		v               Attribute
+		ct              string
+		problem         *Problem
+	}{
+		{ErrorFormatProblem, "", MIMEJSON, invalid, MIMEProblemJSON,
+			&Problem{Type: "about:blank", Title: "Validation Failed", Status: 422, Instance: "/orders?page=1", InvalidParams: []InvalidParam{{"name", "is required"}}}},
+		{ErrorFormatProblem, "https://errors.example.com/", MIMEJSON, notFound, MIMEProblemJSON,
+			&Problem{Type: "https://errors.example.com/not-found", Title: "Not Found", Status: 404, Detail: "Order not found", Instance: "/orders?page=1", Code: "order_not_found"}},
+		{ErrorFormatProblem, "", MIMECSV, notFound, MIMEProblemJSON, nil},
+		{ErrorFormatProblem, "", MIMEXML, notFound, MIMEProblemXML, nil},
+		{ErrorFormatProblem, "", MIMEJSON, Attribute{Code: 200, Status: StatusSuccess}, MIMEJSON, nil},
+		{ErrorFormatDefault, "", MIMEJSON, notFound, MIMEJSON, nil},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		ErrorFormat, ProblemTypeURI = tt.format, tt.typeURI
+
+		req := httptest.NewRequest("GET", "/orders?page=1", nil)
+		req.Header.Set(HeaderAccept, tt.accept)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(standard.NewRequest(req, e.Logger()), standard.NewResponse(rec, e.Logger()))
+
+		if err := Render(c, tt.v.Code, tt.v); err != nil {
+			t.Fatal(err)
+		}
+
+		if ct := rec.Header().Get(echo.HeaderContentType); rec.Code != tt.v.Code || !strings.HasPrefix(ct, tt.ct+";") {
+			t.Errorf("%s %s %d: got %d %s, want %s", tt.format, tt.accept, tt.v.Code, rec.Code, ct, tt.ct)
+		}
+
+		if tt.problem != nil {
+			p := new(Problem)
+			json.Unmarshal(rec.Body.Bytes(), p)
+			if !reflect.DeepEqual(p, tt.problem) {
+				t.Errorf("%s %d: got %+v, want %+v", tt.format, tt.v.Code, p, tt.problem)
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+type (
+	// Renderer writes a response value in its media type.
+	Renderer interface {
+		Render(w io.Writer, v interface{}) error
+	}
+
+	// RendererFunc adapts a function to the Renderer interface.
+	RendererFunc func(w io.Writer, v interface{}) error
+)
+
+// ErrNotRenderable is returned by a renderer that cannot write the value,
+// the response is then rendered with the default media type.
+var ErrNotRenderable = errors.New("value can not be rendered in this media type")
+
+var (
+	renderers   = map[string]Renderer{}
+	aliases     = map[string]string{}
+	defaultType = MIMEJSON
+	mutex       sync.RWMutex
+)
+
+const (
+	MIMEJSON    = "application/json"
+	MIMEXML     = "application/xml"
+	MIMEMsgpack = "application/msgpack"
+	MIMECSV     = "text/csv"
+	MIMEYAML    = "application/x-yaml"
+
+	// HeaderAccept is missing from the echo v2 header constants.
+	HeaderAccept = "Accept"
+)
+
+func init() {
+	Register(MIMEJSON, RendererFunc(renderJSON), "json", MIMEProblemJSON)
+	Register(MIMEXML, RendererFunc(renderXML), "xml", "text/xml", MIMEProblemXML)
+	Register(MIMEMsgpack, RendererFunc(renderMsgpack), "msgpack", "application/x-msgpack")
+	Register(MIMECSV, RendererFunc(renderCSV), "csv")
+	Register(MIMEYAML, RendererFunc(renderYAML), "yaml", "application/yaml", "text/yaml")
+}
+
+func (f RendererFunc) Render(w io.Writer, v interface{}) error {
+	return f(w, v)
+}
+
+// Register adds the renderer for the media type,
+// aliases are short names or other media types served by it.
+func Register(mediaType string, r Renderer, alias ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	renderers[mediaType] = r
+	for _, a := range alias {
+		aliases[a] = mediaType
+	}
+}
+
+// SetDefaultType sets the media type, or its short name such as "xml",
+// used when the Accept header does not match any renderer.
+func SetDefaultType(t string) error {
+	mt, ok := lookup(t)
+	if !ok {
+		return errors.New("unknown response type " + t)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	defaultType = mt
+
+	return nil
+}
+
+func lookup(t string) (string, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	t = strings.ToLower(strings.TrimSpace(t))
+	if a, ok := aliases[t]; ok {
+		t = a
+	}
+
+	_, ok := renderers[t]
+
+	return t, ok
+}
+
+// Negotiate returns the media type with the highest quality in the Accept
+// header that has a renderer, or the default media type.
+func Negotiate(accept string) string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+
+	var list []accepted
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+
+		list = append(list, accepted{mt, q})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+
+	mutex.RLock()
+	def := defaultType
+	mutex.RUnlock()
+
+	for _, a := range list {
+		if a.q <= 0 {
+			continue
+		}
+
+		if a.mediaType == "*/*" || a.mediaType == "application/*" && strings.HasPrefix(def, "application/") {
+			return def
+		}
+
+		if mt, ok := lookup(a.mediaType); ok {
+			return mt
+		}
+	}
+
+	return def
+}
+
+// Render writes v with the status code in the media type
+// negotiated from the Accept header of the request.
+func Render(c echo.Context, code int, v interface{}) error {
+	mt := Negotiate(c.Request().Header().Get(HeaderAccept))
+
+	p, problem := problemOf(v, c.Request().URI())
+	if problem {
+		// problem documents are only defined in json and xml
+		v = p
+		if mt != MIMEXML {
+			mt = MIMEJSON
+		}
+	}
+
+	mutex.RLock()
+	r, def, js := renderers[mt], renderers[defaultType], renderers[MIMEJSON]
+	mutex.RUnlock()
+
+	buf := new(bytes.Buffer)
+	err := r.Render(buf, v)
+	if err == ErrNotRenderable && mt != defaultType {
+		mt, r = defaultType, def
+		buf.Reset()
+		err = r.Render(buf, v)
+	}
+
+	// the default type can be csv as well
+	if err == ErrNotRenderable && mt != MIMEJSON {
+		mt, r = MIMEJSON, js
+		buf.Reset()
+		err = r.Render(buf, v)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case problem && mt == MIMEXML:
+		mt = MIMEProblemXML
+	case problem:
+		mt = MIMEProblemJSON
+	}
+
+	if mt != MIMEMsgpack {
+		mt += "; charset=utf-8"
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, mt)
+	c.Response().WriteHeader(code)
+	_, err = c.Response().Write(buf.Bytes())
+
+	return err
+}
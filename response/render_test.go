@@ -0,0 +1,47 @@
+package response
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/standard"
+)
+
+func TestRenderCSV(t *testing.T) {
+	type row struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		v    Attribute
+		code int
+		ct   string
+		body string
+	}{
+		{Attribute{Status: StatusSuccess, Data: []row{{1, "a"}, {2, "b,c"}}}, 200, MIMECSV, "id,name\n1,a\n2,\"b,c\"\n"},
+		{Attribute{Status: StatusSuccess, Data: []row{}}, 200, MIMECSV, ""},
+		{Attribute{Status: StatusSuccess, Data: row{1, "a"}}, 200, MIMEJSON, `"name":"a"`},
+		{Attribute{Status: StatusFailed, Message: "Not Found"}, 404, MIMEJSON, `"message":"Not Found"`},
+		{Attribute{Status: StatusSuccess}, 200, MIMEJSON, `"status":"success"`},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(HeaderAccept, MIMECSV)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(standard.NewRequest(req, e.Logger()), standard.NewResponse(rec, e.Logger()))
+
+		if err := Render(c, tt.code, tt.v); err != nil {
+			t.Fatal(err)
+		}
+
+		ct := rec.Header().Get(echo.HeaderContentType)
+		if rec.Code != tt.code || !strings.HasPrefix(ct, tt.ct) || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%+v: got %d %s %q", tt.v, rec.Code, ct, rec.Body.String())
+		}
+	}
+}
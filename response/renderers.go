@@ -0,0 +1,244 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+	"gopkg.in/yaml.v2"
+)
+
+func renderJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func renderMsgpack(w io.Writer, v interface{}) error {
+	g, err := generic(v)
+	if err != nil {
+		return err
+	}
+
+	return msgpack.NewEncoder(w).Encode(g)
+}
+
+func renderYAML(w io.Writer, v interface{}) error {
+	g, err := generic(v)
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(g)
+	if err == nil {
+		_, err = w.Write(b)
+	}
+
+	return err
+}
+
+// renderXML writes the value as a response element, objects are written
+// with an element per key and lists with an item element per value.
+func renderXML(w io.Writer, v interface{}) error {
+	g, err := generic(v)
+	if err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	root := xml.StartElement{Name: xml.Name{Local: "response"}}
+	if _, ok := v.(*Problem); ok {
+		root = xml.StartElement{Name: xml.Name{Space: "urn:ietf:rfc:7807", Local: "problem"}}
+	}
+
+	if err = encodeXML(e, root, g); err != nil {
+		return err
+	}
+
+	return e.Flush()
+}
+
+func encodeXML(e *xml.Encoder, start xml.StartElement, v interface{}) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXML(e, element(k), x[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, i := range x {
+			if err := encodeXML(e, element("item"), i); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := e.EncodeToken(xml.CharData(fmt.Sprint(x))); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func element(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+// renderCSV writes the data list of a response as rows with a header
+// line of the json keys, failed responses and other values are not
+// renderable as csv.
+func renderCSV(w io.Writer, v interface{}) error {
+	if a, ok := v.(Attribute); ok {
+		v = &a
+	}
+
+	if a, ok := v.(*Attribute); ok {
+		if a.Status == StatusFailed {
+			return ErrNotRenderable
+		}
+
+		v = a.Data
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var rows []json.RawMessage
+	if err = json.Unmarshal(b, &rows); err != nil || rows == nil {
+		return ErrNotRenderable
+	}
+
+	cw := csv.NewWriter(w)
+	var header []string
+	for i, raw := range rows {
+		if i == 0 {
+			if header, err = objectKeys(raw); err != nil {
+				return ErrNotRenderable
+			}
+
+			cw.Write(header)
+		}
+
+		var row map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(raw))
+		d.UseNumber()
+		if err = d.Decode(&row); err != nil {
+			return ErrNotRenderable
+		}
+
+		record := make([]string, len(header))
+		for j, k := range header {
+			record[j] = csvValue(row[k])
+		}
+
+		cw.Write(record)
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// objectKeys returns the keys of the json object in their written order.
+func objectKeys(raw json.RawMessage) (keys []string, err error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := d.Token(); err != nil || t != json.Delim('{') {
+		return nil, ErrNotRenderable
+	}
+
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, t.(string))
+
+		var skip json.RawMessage
+		if err = d.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+func csvValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return fmt.Sprint(x)
+	}
+
+	b, _ := json.Marshal(v)
+
+	return string(b)
+}
+
+// generic returns v as json decoded maps, slices and scalars,
+// so every renderer honors the json field names.
+func generic(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var g interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&g); err != nil {
+		return nil, err
+	}
+
+	return numbers(g), nil
+}
+
+// numbers converts json numbers to int64 or float64.
+func numbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, i := range x {
+			x[k] = numbers(i)
+		}
+	case []interface{}:
+		for k, i := range x {
+			x[k] = numbers(i)
+		}
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := x.Float64()
+
+		return f
+	}
+
+	return v
+}
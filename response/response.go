@@ -2,17 +2,33 @@ package response
 
 type (
 	Attribute struct {
-		Code    int               `json:"-"`
-		Status  string            `json:"status,omitempty"`
-		Message interface{}       `json:"message,omitempty"`
-		Data    interface{}       `json:"data,omitempty"`
-		Total   int64             `json:"total,omitempty"`
-		Errors  []ErrorValidation `json:"errors,omitempty"`
+		Code       int               `json:"-"`
+		Status     string            `json:"status,omitempty"`
+		Message    interface{}       `json:"message,omitempty"`
+		ErrorCode  string            `json:"code,omitempty"`
+		Cause      string            `json:"cause,omitempty"`
+		Data       interface{}       `json:"data,omitempty"`
+		Total      int64             `json:"total,omitempty"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+		PrevCursor string            `json:"prev_cursor,omitempty"`
+		Meta       *Meta             `json:"meta,omitempty"`
+		Errors     []ErrorValidation `json:"errors,omitempty"`
+	}
+
+	Meta struct {
+		Page       int    `json:"page,omitempty"`
+		PerPage    int    `json:"per_page"`
+		Total      *int64 `json:"total,omitempty"`
+		TotalPages *int   `json:"total_pages,omitempty"`
+		Next       string `json:"next,omitempty"`
+		Prev       string `json:"prev,omitempty"`
 	}
 
 	ErrorValidation struct {
 		Field   string `json:"field"`
 		Message string `json:"message"`
+		Rule    string `json:"rule,omitempty"`
+		Param   string `json:"param,omitempty"`
 	}
 )
 
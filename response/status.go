@@ -3,9 +3,12 @@ package response
 const (
 	StatusOK                  = 200
 	StatusCreated             = 201
+	StatusMultiStatus         = 207
 	StatusBadRequest          = 400
 	StatusUnauthorized        = 401
+	StatusForbidden           = 403
 	StatusNotFound            = 404
+	StatusConflict            = 409
 	StatusUnprocessableEntry  = 422
 	StatusInternalServerError = 500
 	StatusFailed              = "fail"
@@ -15,9 +18,12 @@ const (
 var statusText = map[int]string{
 	StatusOK:                  "OK",
 	StatusCreated:             "Created",
+	StatusMultiStatus:         "Multi-Status",
 	StatusBadRequest:          "Bad Request",
 	StatusUnauthorized:        "Unauthorized",
+	StatusForbidden:           "Forbidden",
 	StatusNotFound:            "Not Found",
+	StatusConflict:            "Conflict",
 	StatusUnprocessableEntry:  "Validation Failed",
 	StatusInternalServerError: "Internal Server Error",
 }
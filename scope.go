@@ -0,0 +1,205 @@
+package cuxs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qasico/cuxs/helper"
+)
+
+type modelSchema struct {
+	scope     *gorm.Scope
+	columns   map[string]string
+	names     map[string]string
+	fields    map[string]string
+	relations map[string]string
+}
+
+var filterConditions = map[string]string{
+	FilterEq:   "%s = ?",
+	FilterNe:   "%s <> ?",
+	FilterGt:   "%s > ?",
+	FilterGte:  "%s >= ?",
+	FilterLt:   "%s < ?",
+	FilterLte:  "%s <= ?",
+	FilterIn:   "%s IN (?)",
+	FilterLike: "%s LIKE ?",
+}
+
+// ParamError is a query param naming an unknown field of the model,
+// it is responded as a validation error of Param.
+type ParamError struct {
+	Param string
+	Field string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("unknown %s field %s", e.Param, e.Field)
+}
+
+// Apply scopes db with the query param of the request, applying filters,
+// search, id, sort, embed preloads and pagination validated against the model
+// columns. When Count is set the total rows are counted into Total.
+// Unknown fields are added as ParamError on the returned db.
+func (q *QueryParam) Apply(db *gorm.DB, model interface{}) *gorm.DB {
+	if q == nil {
+		return db
+	}
+
+	s := newModelSchema(db, model)
+	db = db.Model(model)
+
+	if len(q.Id) > 0 {
+		db = db.Where(fmt.Sprintf("%s IN (?)", s.scope.Quote(s.scope.PrimaryKey())), q.Id)
+	}
+
+	for _, f := range q.Filters {
+		column, ok := s.columns[f.Field]
+		if !ok {
+			db.AddError(&ParamError{Param: fmt.Sprintf("filter[%s][%s]", f.Field, f.Operator), Field: f.Field})
+			continue
+		}
+
+		db = applyFilter(db, s.scope.Quote(column), f)
+	}
+
+	if q.Search != "" && len(q.search) > 0 {
+		db = q.applySearch(db, s)
+	}
+
+	if q.Count {
+		if c := db.Count(&q.Total); c.Error != nil {
+			return c
+		}
+	}
+
+	if q.CursorMode {
+		db = q.applyKeyset(db, s)
+	} else {
+		for _, o := range q.Sorts {
+			column, ok := s.columns[o.Column]
+			if !ok {
+				db.AddError(&ParamError{Param: "sort", Field: o.Field})
+				continue
+			}
+
+			db = db.Order(o.Order(s.scope.Quote(column)))
+		}
+	}
+
+	db = q.preload(db, s)
+
+	if q.CursorMode {
+		return db
+	}
+
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+
+	return db
+}
+
+// Preload scopes db with the embed preloads of the query param
+// validated against the model relations.
+func (q *QueryParam) Preload(db *gorm.DB, model interface{}) *gorm.DB {
+	if q == nil {
+		return db
+	}
+
+	return q.preload(db, newModelSchema(db, model))
+}
+
+func (q *QueryParam) preload(db *gorm.DB, s *modelSchema) *gorm.DB {
+	for _, e := range q.Embed {
+		if relation, ok := s.relations[e]; ok {
+			db = db.Preload(relation)
+		} else {
+			db.AddError(&ParamError{Param: "embed", Field: e})
+		}
+	}
+
+	return db
+}
+
+func applyFilter(db *gorm.DB, column string, f Filter) *gorm.DB {
+	switch f.Operator {
+	case FilterIsNull:
+		if f.IsNull() {
+			return db.Where(fmt.Sprintf("%s IS NULL", column))
+		}
+
+		return db.Where(fmt.Sprintf("%s IS NOT NULL", column))
+	case FilterIn:
+		return db.Where(fmt.Sprintf(filterConditions[f.Operator], column), f.Value)
+	case FilterLike:
+		return db.Where(fmt.Sprintf(filterConditions[f.Operator], column), "%"+f.Value[0]+"%")
+	}
+
+	return db.Where(fmt.Sprintf(filterConditions[f.Operator], column), f.Value[0])
+}
+
+// applySearch matches the search term in any of the searchable fields.
+func (q *QueryParam) applySearch(db *gorm.DB, s *modelSchema) *gorm.DB {
+	var ors []string
+	var args []interface{}
+	for _, field := range q.search {
+		column, ok := s.columns[field]
+		if !ok {
+			db.AddError(&ParamError{Param: "q", Field: field})
+			continue
+		}
+
+		ors = append(ors, fmt.Sprintf(filterConditions[FilterLike], s.scope.Quote(column)))
+		args = append(args, "%"+q.Search+"%")
+	}
+
+	if len(ors) == 0 {
+		return db
+	}
+
+	return db.Where("("+strings.Join(ors, " OR ")+")", args...)
+}
+
+// newModelSchema maps the json and db names of the model fields
+// to their columns, and the embed names to their relations.
+func newModelSchema(db *gorm.DB, model interface{}) *modelSchema {
+	s := &modelSchema{scope: db.NewScope(model), columns: map[string]string{}, names: map[string]string{}, fields: map[string]string{}, relations: map[string]string{}}
+
+	for _, f := range s.scope.GetModelStruct().StructFields {
+		if f.IsIgnored {
+			continue
+		}
+
+		name := jsonName(f.Tag.Get("json"), f.Name)
+		if name == "-" {
+			continue
+		}
+
+		if f.Relationship != nil {
+			s.relations[name] = f.Name
+		} else if f.IsNormal {
+			s.columns[name] = f.DBName
+			s.names[name] = f.DBName
+			s.columns[f.DBName] = f.DBName
+			s.fields[f.DBName] = f.Name
+		}
+	}
+
+	return s
+}
+
+// jsonName returns the field name used in json, the snake cased
+// struct field name is used when the tag does not set one.
+func jsonName(tag string, field string) string {
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+
+	return helper.SnakeCase(field)
+}
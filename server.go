@@ -0,0 +1,185 @@
+package cuxs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/engine"
+	"github.com/labstack/echo/engine/fasthttp"
+	"github.com/qasico/cuxs/log"
+)
+
+var (
+	shutdownHooks []func()
+	hooksMu       sync.Mutex
+
+	stopping int32
+
+	redirect   *http.Server
+	redirectMu sync.Mutex
+)
+
+// newServer returns the fasthttp engine serving plain HTTP or TLS
+// depending on EnableHTTPS, on the given listener when it is not nil.
+func newServer(c ServerConfig, ln net.Listener) *fasthttp.Server {
+	ec := engine.Config{Address: c.HTTPAddr, Listener: ln}
+	if c.EnableHTTPS {
+		ec.TLSCertFile = c.HTTPSCertFile
+		ec.TLSKeyFile = c.HTTPSKeyFile
+	}
+
+	return fasthttp.WithConfig(ec)
+}
+
+// checkCertificates makes sure the certificate and key are present
+// and can be loaded when HTTPS is enabled.
+func checkCertificates(c ServerConfig) error {
+	if !c.EnableHTTPS {
+		return nil
+	}
+
+	if c.HTTPSCertFile == "" || c.HTTPSKeyFile == "" {
+		return errors.New("SERVER_SSL is enabled but SERVER_CERT or SERVER_KEY is empty")
+	}
+
+	for _, f := range []string{c.HTTPSCertFile, c.HTTPSKeyFile} {
+		if _, err := os.Stat(f); err != nil {
+			return fmt.Errorf("cannot read %s, %s", f, err.Error())
+		}
+	}
+
+	if _, err := tls.LoadX509KeyPair(c.HTTPSCertFile, c.HTTPSKeyFile); err != nil {
+		return fmt.Errorf("invalid certificate %s, %s", c.HTTPSCertFile, err.Error())
+	}
+
+	return nil
+}
+
+func scheme(c ServerConfig) string {
+	if c.EnableHTTPS {
+		return "https"
+	}
+
+	return "http"
+}
+
+// runRedirect listens on RedirectAddr and redirects
+// every plain HTTP request to the HTTPS server.
+func runRedirect(c ServerConfig) {
+	_, port, _ := net.SplitHostPort(c.HTTPAddr)
+
+	s := &http.Server{
+		Addr: c.RedirectAddr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+
+			if port != "" && port != "443" {
+				host = net.JoinHostPort(host, port)
+			}
+
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+	}
+
+	redirectMu.Lock()
+	redirect = s
+	redirectMu.Unlock()
+
+	log.Infof("Redirecting http://%s to https://%s", c.RedirectAddr, c.HTTPAddr)
+	if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Cannot start redirect server, %s", err.Error())
+	}
+}
+
+func stopRedirect() {
+	redirectMu.Lock()
+	defer redirectMu.Unlock()
+
+	if redirect != nil {
+		redirect.Close()
+	}
+}
+
+// OnShutdown registers fn to be called after the server has stopped,
+// before the database connections are closed.
+func OnShutdown(fn func()) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	shutdownHooks = append(shutdownHooks, fn)
+}
+
+// runGraceful serves the application on its own listener and blocks until
+// SIGINT or SIGTERM is received, then stops the server within ServerTimeOut
+// seconds and releases resources.
+func runGraceful() {
+	c := Config.ServerConfig
+
+	ln, err := net.Listen("tcp", c.HTTPAddr)
+	if err != nil {
+		log.Fatalf("Cannot listen on %s, %s", c.HTTPAddr, err.Error())
+	}
+
+	s := newServer(c, ln)
+	go func() {
+		if err := Echo.Run(s); err != nil && atomic.LoadInt32(&stopping) == 0 {
+			log.Fatalf("Server stopped, %s", err.Error())
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	log.Warnf("Received %s, shutting down server ...", <-sig)
+
+	stopServer(s, time.Duration(c.ServerTimeOut)*time.Second)
+}
+
+// stopServer closes the listener and the idle connections, waits until the
+// responses being served are written, then releases resources. Kept-alive
+// connections are closed after their current response. A zero timeout
+// waits without limit.
+func stopServer(s *fasthttp.Server, timeout time.Duration) {
+	atomic.StoreInt32(&stopping, 1)
+	stopRedirect()
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := s.ShutdownWithContext(ctx); err != nil {
+		log.Warnf("Server timeout reached with requests still running, %s", err.Error())
+	}
+
+	shutdown()
+}
+
+// shutdown runs the registered hooks and closes the databases.
+func shutdown() {
+	hooksMu.Lock()
+	hooks := shutdownHooks
+	hooksMu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+
+	CloseDB()
+
+	log.Infof("Server stopped")
+}
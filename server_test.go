@@ -0,0 +1,142 @@
+package cuxs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+)
+
+func TestStopServer(t *testing.T) {
+	defer atomic.StoreInt32(&stopping, 0)
+
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Orm["stop"] = db
+
+	hooks := shutdownHooks
+	defer func() { shutdownHooks = hooks }()
+
+	open := false
+	OnShutdown(func() { _, open = Orm["stop"] })
+
+	started := make(chan struct{})
+	e := echo.New()
+	e.GET("/slow", func(c echo.Context) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+
+		return c.String(http.StatusOK, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newServer(ServerConfig{}, ln)
+	go e.Run(s)
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+
+		b, _ := ioutil.ReadAll(res.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	stopServer(s, 5*time.Second)
+
+	select {
+	case b := <-body:
+		if b != "done" {
+			t.Errorf("response cut by the shutdown: %s", b)
+		}
+	case <-time.After(time.Second):
+		t.Error("no response after the shutdown")
+	}
+
+	if !open {
+		t.Error("shutdown hooks ran after the databases were closed")
+	}
+
+	if _, ok := Orm["stop"]; ok {
+		t.Error("databases left open")
+	}
+}
+
+// writeKeyPair writes a self signed certificate and its key in dir.
+func writeKeyPair(t *testing.T, dir string, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+
+	return cert, keyFile
+}
+
+func TestCheckCertificates(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeKeyPair(t, dir, "a")
+	_, other := writeKeyPair(t, dir, "b")
+
+	garbage := filepath.Join(dir, "garbage.crt")
+	ioutil.WriteFile(garbage, []byte("not a certificate"), 0600)
+
+	tests := []struct {
+		name   string
+		config ServerConfig
+		valid  bool
+	}{
+		{"disabled", ServerConfig{HTTPSCertFile: "missing.crt"}, true},
+		{"valid", ServerConfig{EnableHTTPS: true, HTTPSCertFile: cert, HTTPSKeyFile: key}, true},
+		{"empty cert", ServerConfig{EnableHTTPS: true, HTTPSKeyFile: key}, false},
+		{"empty key", ServerConfig{EnableHTTPS: true, HTTPSCertFile: cert}, false},
+		{"missing cert", ServerConfig{EnableHTTPS: true, HTTPSCertFile: filepath.Join(dir, "missing.crt"), HTTPSKeyFile: key}, false},
+		{"missing key", ServerConfig{EnableHTTPS: true, HTTPSCertFile: cert, HTTPSKeyFile: filepath.Join(dir, "missing.key")}, false},
+		{"invalid cert", ServerConfig{EnableHTTPS: true, HTTPSCertFile: garbage, HTTPSKeyFile: key}, false},
+		{"key of another cert", ServerConfig{EnableHTTPS: true, HTTPSCertFile: cert, HTTPSKeyFile: other}, false},
+	}
+
+	for _, tt := range tests {
+		if err := checkCertificates(tt.config); (err == nil) != tt.valid {
+			t.Errorf("%s: got %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cuxs
+
+import (
+	"regexp"
+	"strings"
+)
+
+type (
+	// SortField is a single column parsed from ?sort=-created_at,name:nulls_last.
+	SortField struct {
+		Field  string
+		Column string
+		Desc   bool
+		Nulls  string
+	}
+
+	// SortRules whitelists the sortable json fields of a resource
+	// and maps them to their db columns.
+	SortRules map[string]string
+)
+
+const (
+	NullsFirst = "first"
+	NullsLast  = "last"
+)
+
+var matchSort = regexp.MustCompile(`^(-)?([A-Za-z0-9_.]+)(?::nulls_(first|last))?$`)
+
+// parseSort reads the comma separated sort query string,
+// malformed values are added as validation errors on the response.
+func (h *Handler) parseSort(param string) {
+	for _, v := range strings.Split(param, ",") {
+		m := matchSort.FindStringSubmatch(strings.TrimSpace(v))
+		if m == nil {
+			h.setParamError("sort", "invalid", v)
+			continue
+		}
+
+		h.QueryParam.Sorts = append(h.QueryParam.Sorts, SortField{Field: m[2], Column: m[2], Desc: m[1] == "-", Nulls: m[3]})
+	}
+
+	h.QueryParam.Sort = orderClause(h.QueryParam.Sorts)
+}
+
+// AllowSorts checks the parsed sorts against the resource whitelist and
+// maps them to their columns, rejected sorts are added as validation
+// errors on the response.
+func (h *Handler) AllowSorts(rules SortRules) (valid bool) {
+	valid = true
+	if h.QueryParam == nil {
+		return
+	}
+
+	for i, s := range h.QueryParam.Sorts {
+		if column, ok := rules[s.Field]; ok {
+			h.QueryParam.Sorts[i].Column = column
+		} else {
+			h.setParamError("sort", "sortable", s.Field)
+			valid = false
+		}
+	}
+
+	h.QueryParam.Sort = orderClause(h.QueryParam.Sorts)
+
+	return
+}
+
+// Order returns the order clause of the sort on column. Nulls ordering
+// sorts on a CASE expression, as NULLS FIRST/LAST is missing on MySQL and
+// SQL Server and the latter cannot sort on a boolean expression.
+func (s SortField) Order(column string) string {
+	order := column + " asc"
+	if s.Desc {
+		order = column + " desc"
+	}
+
+	switch s.Nulls {
+	case NullsFirst:
+		order = "CASE WHEN " + column + " IS NULL THEN 0 ELSE 1 END asc, " + order
+	case NullsLast:
+		order = "CASE WHEN " + column + " IS NULL THEN 0 ELSE 1 END desc, " + order
+	}
+
+	return order
+}
+
+func orderClause(sorts []SortField) string {
+	orders := make([]string, 0, len(sorts))
+	for _, s := range sorts {
+		orders = append(orders, s.Order(s.Column))
+	}
+
+	return strings.Join(orders, ", ")
+}
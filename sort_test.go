@@ -0,0 +1,84 @@
+package cuxs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qasico/cuxs/response"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in     string
+		sorts  []SortField
+		clause string
+		errors int
+	}{
+		{"name", []SortField{{Field: "name", Column: "name"}}, "name asc", 0},
+		{"-created_at", []SortField{{Field: "created_at", Column: "created_at", Desc: true}}, "created_at desc", 0},
+		{"-a, b", []SortField{{Field: "a", Column: "a", Desc: true}, {Field: "b", Column: "b"}}, "a desc, b asc", 0},
+		{"a:nulls_last", []SortField{{Field: "a", Column: "a", Nulls: NullsLast}}, "CASE WHEN a IS NULL THEN 0 ELSE 1 END desc, a asc", 0},
+		{"-a:nulls_first", []SortField{{Field: "a", Column: "a", Desc: true, Nulls: NullsFirst}}, "CASE WHEN a IS NULL THEN 0 ELSE 1 END asc, a desc", 0},
+		{"a;drop table x", nil, "", 1},
+		{"a:nulls_middle", nil, "", 1},
+		{"a,,b", []SortField{{Field: "a", Column: "a"}, {Field: "b", Column: "b"}}, "a asc, b asc", 1},
+	}
+
+	for _, tt := range tests {
+		h := &Handler{QueryParam: new(QueryParam), Response: new(response.Attribute)}
+		h.parseSort(tt.in)
+
+		if !reflect.DeepEqual(h.QueryParam.Sorts, tt.sorts) || h.QueryParam.Sort != tt.clause || len(h.Response.Errors) != tt.errors {
+			t.Errorf("%q: got %v %q %v", tt.in, h.QueryParam.Sorts, h.QueryParam.Sort, h.Response.Errors)
+		}
+	}
+}
+
+func TestAllowSorts(t *testing.T) {
+	h := &Handler{QueryParam: new(QueryParam), Response: new(response.Attribute)}
+	h.parseSort("-name,secret")
+
+	if h.AllowSorts(SortRules{"name": "users.full_name"}) {
+		t.Fatal("secret is sortable")
+	}
+
+	if h.QueryParam.Sort != "users.full_name desc, secret asc" || len(h.Response.Errors) != 1 {
+		t.Fatalf("got %q %v", h.QueryParam.Sort, h.Response.Errors)
+	}
+}
+
+func TestSortNulls(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	a, b := "a", "b"
+	db.AutoMigrate(&patchModel{})
+	db.Create(&patchModel{Id: 1, Note: &b})
+	db.Create(&patchModel{Id: 2})
+	db.Create(&patchModel{Id: 3, Note: &a})
+
+	tests := []struct {
+		sort SortField
+		ids  []int64
+	}{
+		{SortField{Column: "note", Nulls: NullsFirst}, []int64{2, 3, 1}},
+		{SortField{Column: "note", Nulls: NullsLast}, []int64{3, 1, 2}},
+		{SortField{Column: "note", Desc: true, Nulls: NullsFirst}, []int64{2, 1, 3}},
+		{SortField{Column: "note", Desc: true, Nulls: NullsLast}, []int64{1, 3, 2}},
+	}
+
+	for _, tt := range tests {
+		var ids []int64
+		if err := db.Model(&patchModel{}).Order(tt.sort.Order("note")).Pluck("id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%+v: got %v, want %v", tt.sort, ids, tt.ids)
+		}
+	}
+}
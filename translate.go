@@ -0,0 +1,244 @@
+package cuxs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+// Catalog maps validator tags and the rules of the query params to message
+// templates, "{param}" is replaced by the tag param. A tag can have kind specific templates such as
+// "min.string" and "min.number" used before the plain "min", and a
+// "password.default" template used when the tag has no param.
+type Catalog map[string]string
+
+var (
+	catalogs = map[string]Catalog{
+		"en": {
+			"required":         "is required",
+			"min.string":       "must be at least {param} characters",
+			"min.slice":        "must contain at least {param} items",
+			"min.number":       "must be {param} or greater",
+			"max.string":       "must be at most {param} characters",
+			"max.slice":        "must contain at most {param} items",
+			"max.number":       "must be {param} or less",
+			"len.string":       "must be {param} characters long",
+			"len.slice":        "must contain {param} items",
+			"len":              "must be equal to {param}",
+			"eq":               "must be equal to {param}",
+			"ne":               "must not be equal to {param}",
+			"gt":               "must be greater than {param}",
+			"gte":              "must be {param} or greater",
+			"lt":               "must be less than {param}",
+			"lte":              "must be {param} or less",
+			"eqfield":          "must be equal to {param}",
+			"nefield":          "must not be equal to {param}",
+			"email":            "must be a valid email address",
+			"url":              "must be a valid url",
+			"numeric":          "must be a number",
+			"alpha":            "must contain only letters",
+			"alphanum":         "must contain only letters and numbers",
+			"idcodec":          "is not a valid id",
+			"unique":           "has already been taken",
+			"exist":            "does not exist",
+			"date":             "must be a valid date",
+			"phone":            "must be a valid phone number",
+			"enum":             "must be one of {param}",
+			"password":         "must be at least {param} characters with upper and lower case letters and a digit",
+			"password.default": "must be at least 8 characters with upper and lower case letters and a digit",
+			"invalid":          "is invalid",
+			"readonly":         "cannot be changed",
+			"type":             "must be a valid {param}",
+			"unknown":          "is not a known field",
+			"null":             "cannot be null",
+			"unknown_field":    "{param} is not a known field",
+			"subfields":        "{param} has no sub fields",
+			"syntax":           "has a syntax error at position {param}",
+			"boolean":          "must be true or false",
+			"page":             "must be a number greater than 0",
+			"page_size":        "must be a number between 1 and {param}",
+			"range":            "must contain 1 to {param} items",
+			"operator":         "operator {param} is not allowed",
+			"filterable":       "{param} is not filterable",
+			"sortable":         "{param} is not sortable",
+			"searchable":       "search is not supported",
+			"allowed":          "is not allowed",
+			"transactional":    "does not support transactions",
+			"patch_op":         "operation {param} is not supported",
+			"patch_path":       "path {param} is invalid",
+			"patch_found":      "path {param} does not exist",
+			"patch_test":       "test failed on {param}",
+		},
+		"id": {
+			"required":         "wajib diisi",
+			"min.string":       "minimal {param} karakter",
+			"min.slice":        "minimal berisi {param} item",
+			"min.number":       "minimal {param}",
+			"max.string":       "maksimal {param} karakter",
+			"max.slice":        "maksimal berisi {param} item",
+			"max.number":       "maksimal {param}",
+			"len.string":       "harus {param} karakter",
+			"len.slice":        "harus berisi {param} item",
+			"len":              "harus sama dengan {param}",
+			"eq":               "harus sama dengan {param}",
+			"ne":               "tidak boleh sama dengan {param}",
+			"gt":               "harus lebih besar dari {param}",
+			"gte":              "minimal {param}",
+			"lt":               "harus lebih kecil dari {param}",
+			"lte":              "maksimal {param}",
+			"eqfield":          "harus sama dengan {param}",
+			"nefield":          "tidak boleh sama dengan {param}",
+			"email":            "harus berupa alamat email yang valid",
+			"url":              "harus berupa url yang valid",
+			"numeric":          "harus berupa angka",
+			"alpha":            "hanya boleh berisi huruf",
+			"alphanum":         "hanya boleh berisi huruf dan angka",
+			"idcodec":          "bukan id yang valid",
+			"unique":           "sudah digunakan",
+			"exist":            "tidak ditemukan",
+			"date":             "harus berupa tanggal yang valid",
+			"phone":            "harus berupa nomor telepon yang valid",
+			"enum":             "harus salah satu dari {param}",
+			"password":         "minimal {param} karakter dengan huruf besar, huruf kecil dan angka",
+			"password.default": "minimal 8 karakter dengan huruf besar, huruf kecil dan angka",
+			"invalid":          "tidak valid",
+			"readonly":         "tidak dapat diubah",
+			"type":             "harus berupa {param} yang valid",
+			"unknown":          "bukan field yang dikenal",
+			"null":             "tidak boleh kosong",
+			"unknown_field":    "{param} bukan field yang dikenal",
+			"subfields":        "{param} tidak memiliki sub field",
+			"syntax":           "salah penulisan pada posisi {param}",
+			"boolean":          "harus bernilai true atau false",
+			"page":             "harus berupa angka lebih besar dari 0",
+			"page_size":        "harus berupa angka antara 1 dan {param}",
+			"range":            "harus berisi 1 sampai {param} item",
+			"operator":         "operator {param} tidak diizinkan",
+			"filterable":       "{param} tidak dapat difilter",
+			"sortable":         "{param} tidak dapat diurutkan",
+			"searchable":       "pencarian tidak didukung",
+			"allowed":          "tidak diizinkan",
+			"transactional":    "tidak mendukung transaksi",
+			"patch_op":         "operasi {param} tidak didukung",
+			"patch_path":       "path {param} tidak valid",
+			"patch_found":      "path {param} tidak ditemukan",
+			"patch_test":       "test gagal pada {param}",
+		},
+	}
+
+	catalogMu sync.RWMutex
+)
+
+// LoadCatalog merges the json catalog file into the locale catalog.
+func LoadCatalog(locale string, file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	c := Catalog{}
+	if err = json.Unmarshal(b, &c); err != nil {
+		return err
+	}
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	if catalogs[locale] == nil {
+		catalogs[locale] = Catalog{}
+	}
+
+	for k, v := range c {
+		catalogs[locale][k] = v
+	}
+
+	return nil
+}
+
+// LoadCatalogs loads every json file of dir, named by their locale such as en.json.
+func LoadCatalogs(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err = LoadCatalog(strings.TrimSuffix(filepath.Base(f), ".json"), f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Translate returns the message of the validator tag in the locale, falling
+// back to the default locale and finally to the tag itself.
+func Translate(locale string, tag string, param string, kind reflect.Kind) string {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	keys := []string{tag}
+	if k := kindName(kind); k != "" {
+		keys = []string{tag + "." + k, tag}
+	}
+
+	if param == "" {
+		keys = append([]string{tag + ".default"}, keys...)
+	}
+
+	for _, l := range []string{locale, Config.Locale} {
+		for _, k := range keys {
+			if t, ok := catalogs[l][k]; ok {
+				return strings.Replace(t, "{param}", param, -1)
+			}
+		}
+	}
+
+	return tag
+}
+
+// Locale returns the first locale of the Accept-Language header that has
+// a catalog, or the default locale for a handler without request.
+func (h *Handler) Locale() string {
+	if h.Context == nil {
+		return Config.Locale
+	}
+
+	return negotiateLocale(h.Context)
+}
+
+func negotiateLocale(c echo.Context) string {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	for _, part := range strings.Split(c.Request().Header().Get("Accept-Language"), ",") {
+		tag := strings.TrimSpace(strings.Split(part, ";")[0])
+		for _, l := range []string{tag, strings.Split(tag, "-")[0]} {
+			if _, ok := catalogs[strings.ToLower(l)]; ok {
+				return strings.ToLower(l)
+			}
+		}
+	}
+
+	return Config.Locale
+}
+
+func kindName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "slice"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+
+	return ""
+}
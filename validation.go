@@ -0,0 +1,200 @@
+package cuxs
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+var (
+	validate = newValidator()
+
+	// validating maps the request structs being validated by a handler to
+	// the handler, the unique and exist rules run on its database.
+	validating sync.Map
+
+	matchIdentifier = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	matchPhone      = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+)
+
+func newValidator() *validator.Validate {
+	v := validator.New(&validator.Config{TagName: "validate"})
+	v.RegisterValidation("encrypted", Validencrypted)
+	v.RegisterValidation("idcodec", ValidIdcodec)
+	v.RegisterValidation("unique", ValidUnique)
+	v.RegisterValidation("exist", ValidExists)
+	v.RegisterValidation("date", ValidDate)
+	v.RegisterValidation("phone", ValidPhone)
+	v.RegisterValidation("enum", ValidEnum)
+	v.RegisterValidation("password", ValidPassword)
+
+	return v
+}
+
+// Validator returns the validator shared by every handler.
+func Validator() *validator.Validate {
+	return validate
+}
+
+// RegisterValidation adds a custom rule to the shared validator,
+// it should be called before the server starts.
+func RegisterValidation(tag string, fn validator.Func) error {
+	return validate.RegisterValidation(tag, fn)
+}
+
+// ValidUnique checks with unique=table.column that no other row has the
+// value. The row changed by the handler, or else the row of the Id field
+// of the struct, is excluded.
+func ValidUnique(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+	db, id := ruleScope(topStruct)
+	q, ok := columnQuery(db, param, field)
+	if !ok {
+		return false
+	}
+
+	if id != nil {
+		q = q.Where(db.Dialect().Quote("id")+" <> ?", id)
+	}
+
+	var n int
+	if err := q.Count(&n).Error; err != nil {
+		return false
+	}
+
+	return n == 0
+}
+
+// ValidExists checks with exist=table.column that a row has the value,
+// the validator reserves the exists tag.
+func ValidExists(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+	db, _ := ruleScope(topStruct)
+	db, ok := columnQuery(db, param, field)
+	if !ok {
+		return false
+	}
+
+	var n int
+	if err := db.Count(&n).Error; err != nil {
+		return false
+	}
+
+	return n > 0
+}
+
+// ValidDate checks the string is a date in the param layout, default to 2006-01-02.
+func ValidDate(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+	if field.Kind() != reflect.String {
+		return false
+	}
+
+	if param == "" {
+		param = "2006-01-02"
+	}
+
+	_, err := time.Parse(param, field.String())
+
+	return err == nil
+}
+
+// ValidPhone checks the string is a phone number of 7 to 15 digits,
+// optionally starting with + and separated by spaces or dashes.
+func ValidPhone(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+	if field.Kind() != reflect.String {
+		return false
+	}
+
+	p := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(field.String())
+
+	return matchPhone.MatchString(p)
+}
+
+// ValidEnum checks the value is one of the space separated
+// values of the param, such as enum=active inactive.
+func ValidEnum(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+	return inStrings(strings.Fields(param), fmt.Sprint(field.Interface()))
+}
+
+// ValidPassword checks the string has lower and upper case letters,
+// a digit and at least param characters, default to 8.
+func ValidPassword(v *validator.Validate, topStruct reflect.Value, currentStructOrField reflect.Value, field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+	if field.Kind() != reflect.String {
+		return false
+	}
+
+	min := 8
+	if n, err := strconv.Atoi(param); err == nil {
+		min = n
+	}
+
+	var lower, upper, digit bool
+	for _, r := range field.String() {
+		lower = lower || unicode.IsLower(r)
+		upper = upper || unicode.IsUpper(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+
+	return lower && upper && digit && len([]rune(field.String())) >= min
+}
+
+func columnQuery(db *gorm.DB, param string, field reflect.Value) (*gorm.DB, bool) {
+	p := strings.Split(param, ".")
+	if len(p) != 2 || !matchIdentifier.MatchString(p[0]) || !matchIdentifier.MatchString(p[1]) || db == nil {
+		return nil, false
+	}
+
+	// Table quotes the name itself
+	return db.Table(p[0]).Where(db.Dialect().Quote(p[1])+" = ?", field.Interface()), true
+}
+
+// ruleScope returns the database of the unique and exist rules and the
+// row excluded from unique, taken from the handler validating top when
+// there is one, else ORM and the Id field of top.
+func ruleScope(top reflect.Value) (*gorm.DB, interface{}) {
+	// Struct passes the pointer, StructPartial the struct it points to
+	if top.Kind() == reflect.Struct && top.CanAddr() {
+		top = top.Addr()
+	}
+
+	if top.Kind() == reflect.Ptr {
+		if v, ok := validating.Load(top.Pointer()); ok {
+			h := v.(*Handler)
+			db := h.DB
+			if db == nil {
+				db = DBOf(h.Context)
+			}
+
+			if h.RowID != nil {
+				return db, h.RowID
+			}
+
+			return db, idOf(top)
+		}
+	}
+
+	return ORM(), idOf(top)
+}
+
+func idOf(s reflect.Value) interface{} {
+	s = reflect.Indirect(s)
+	if s.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if f := s.FieldByName("Id"); f.IsValid() && f.CanInterface() && !isZero(f) {
+		return f.Interface()
+	}
+
+	return nil
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}